@@ -4,9 +4,10 @@ import (
 	"crypto/rand"
 	"fmt"
 	"math/big"
-	"runtime"
 	"testing"
 	"time"
+
+	"CInG/leaktest"
 )
 
 func GenerateIntA(done chan struct{}) chan int {
@@ -32,6 +33,7 @@ func GenerateIntA(done chan struct{}) chan int {
 }
 
 func Test1(t *testing.T) {
+	leaktest.Check(t) // close(done) 之后 GenerateIntA 的协程必须退出，否则测试失败并打印它的调用栈
 	done := make(chan struct{})
 	ch := GenerateIntA(done)
 
@@ -43,9 +45,6 @@ func Test1(t *testing.T) {
 
 	fmt.Println(<-ch)
 	fmt.Println(<-ch)
-
-	time.Sleep(2 * time.Second)
-	println("NumGoroutine=", runtime.NumGoroutine())
 }
 
 // 模拟后台工作协程
@@ -76,6 +75,7 @@ func backgroundWorker(done chan struct{}) chan string {
 }
 
 func Test2(t *testing.T) {
+	leaktest.Check(t)
 	done := make(chan struct{}) // 退出通知通道
 	results := backgroundWorker(done)
 
@@ -0,0 +1,299 @@
+// Package fakeserver 可编程的假后端，取代 other/gojingjin/33/6_timeout_and_cancel_model.go 里
+// fakeWeatherServer、fakeWeatherServer1、fakeWeatherServer2 这三份“睡一会儿再返回 name:ok”的拷贝。
+// 它基于 httptest.Server，可以注入延迟、错误、慢响应体和连接重置，也可以按脚本依次返回指定的响应，
+// 并记录收到的每一个请求。
+package fakeserver
+
+import (
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// LatencyDist 响应延迟分布。
+type LatencyDist func(r *rand.Rand) time.Duration
+
+// FixedLatency 固定延迟。
+func FixedLatency(d time.Duration) LatencyDist {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// UniformLatency [min, max) 上的均匀分布。
+func UniformLatency(min, max time.Duration) LatencyDist {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int64N(int64(max-min)))
+	}
+}
+
+// NormalLatency 正态分布，结果不小于0。
+func NormalLatency(mean, stddev time.Duration) LatencyDist {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(math.Max(0, float64(mean)+r.NormFloat64()*float64(stddev)))
+	}
+}
+
+// LongTailLatency 长尾：大部分请求按 base 分布，tailRate 比例的请求按 tail 分布，用来模拟 p99 很高的后端。
+func LongTailLatency(base, tail LatencyDist, tailRate float64) LatencyDist {
+	return func(r *rand.Rand) time.Duration {
+		if r.Float64() < tailRate {
+			return tail(r)
+		}
+		return base(r)
+	}
+}
+
+// Response 一次响应的行为。零值表示立即返回200和默认的响应体。
+type Response struct {
+	Latency time.Duration // 返回响应头之前等待的时间
+	Status  int           // 0表示200
+	Body    string        // 空字符串表示 Config.Body
+
+	// Reset 为 true 时不返回响应，等完 Latency 之后直接重置连接。
+	// 注意 net/http 的客户端在复用的连接上遇到重置时，会自动重试一次 GET 这样的幂等请求。
+	Reset bool
+
+	// ChunkSize 大于0时响应体每次只写 ChunkSize 个字节，每写一次等 ChunkDelay，模拟慢响应体。
+	ChunkSize  int
+	ChunkDelay time.Duration
+}
+
+// Config 假后端的配置。Script 里的响应按请求到达的顺序依次使用，用完之后按其余字段随机生成。
+type Config struct {
+	Name string
+
+	Latency LatencyDist // 为nil时没有延迟
+	Status  int         // 正常的状态码，0表示200
+	Body    string      // 空字符串表示 Name + ":ok"
+
+	ErrorRate   float64 // 以这个概率返回 ErrorStatus
+	ErrorStatus int     // 0表示500
+	ResetRate   float64 // 以这个概率重置连接
+
+	ChunkSize  int // 见 Response.ChunkSize
+	ChunkDelay time.Duration
+
+	Script []Response
+	Seed   uint64 // 随机数种子，相同的种子和请求顺序得到相同的行为
+}
+
+// Request 收到的一个请求。
+type Request struct {
+	Method   string
+	Path     string
+	Header   http.Header
+	Body     []byte
+	At       time.Time
+	Response Response // 对这个请求采取的行为
+	Canceled bool     // 客户端在响应写完之前放弃了请求
+}
+
+// Server 假后端。用完之后调用 Close。
+type Server struct {
+	*httptest.Server
+	cfg  Config
+	done chan struct{} // Close 时关闭，让还在等待的请求立即结束
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	requests []*Request
+}
+
+// New 启动一个假后端。
+func New(cfg Config) *Server {
+	if cfg.Body == "" {
+		cfg.Body = cfg.Name + ":ok"
+	}
+	s := &Server{
+		cfg:  cfg,
+		done: make(chan struct{}),
+		rng:  rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Close 关闭假后端，还在等待延迟或者慢慢写响应体的请求会立即结束。
+func (s *Server) Close() {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	s.Server.Close()
+}
+
+// Requests 返回到目前为止收到的所有请求，按到达顺序排列。
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := make([]Request, len(s.requests))
+	for i, r := range s.requests {
+		rs[i] = *r
+	}
+	return rs
+}
+
+// Count 收到的请求数。
+func (s *Server) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// Canceled 被客户端放弃的请求数。
+func (s *Server) Canceled() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r.Canceled {
+			n++
+		}
+	}
+	return n
+}
+
+// next 记录请求，决定这次的响应。
+func (s *Server) next(r *http.Request) *Request {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var resp Response
+	if n := len(s.requests); n < len(s.cfg.Script) {
+		resp = s.cfg.Script[n]
+	} else {
+		resp = s.random()
+	}
+	if resp.Body == "" {
+		resp.Body = s.cfg.Body
+	}
+	if resp.Status == 0 {
+		resp.Status = http.StatusOK
+	}
+
+	req := &Request{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Header:   r.Header.Clone(),
+		Body:     body,
+		At:       time.Now(),
+		Response: resp,
+	}
+	s.requests = append(s.requests, req)
+	return req
+}
+
+// random 调用方持有 s.mu。
+func (s *Server) random() Response {
+	resp := Response{
+		Status:     s.cfg.Status,
+		ChunkSize:  s.cfg.ChunkSize,
+		ChunkDelay: s.cfg.ChunkDelay,
+	}
+	if s.cfg.Latency != nil {
+		resp.Latency = s.cfg.Latency(s.rng)
+	}
+	// 两个概率都抽一次，保证同一个种子下每个请求消耗的随机数个数相同
+	errored := s.rng.Float64() < s.cfg.ErrorRate
+	reset := s.rng.Float64() < s.cfg.ResetRate
+	switch {
+	case reset:
+		resp.Reset = true
+	case errored:
+		resp.Status = s.cfg.ErrorStatus
+		if resp.Status == 0 {
+			resp.Status = http.StatusInternalServerError
+		}
+	}
+	return resp
+}
+
+func (s *Server) canceled(req *Request) {
+	s.mu.Lock()
+	req.Canceled = true
+	s.mu.Unlock()
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	req := s.next(r)
+	resp := req.Response
+
+	if !s.wait(r, resp.Latency) {
+		s.canceled(req)
+		return
+	}
+
+	if resp.Reset {
+		reset(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(resp.Status)
+	if resp.ChunkSize <= 0 {
+		io.WriteString(w, resp.Body)
+		return
+	}
+
+	f, _ := w.(http.Flusher)
+	for body := resp.Body; len(body) > 0; {
+		n := min(resp.ChunkSize, len(body))
+		if _, err := io.WriteString(w, body[:n]); err != nil {
+			s.canceled(req)
+			return
+		}
+		if f != nil {
+			f.Flush()
+		}
+		body = body[n:]
+		if len(body) > 0 && !s.wait(r, resp.ChunkDelay) {
+			s.canceled(req)
+			return
+		}
+	}
+}
+
+// wait 等待 d，客户端放弃请求或者服务器关闭时返回 false。
+func (s *Server) wait(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return r.Context().Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-s.done:
+		return false
+	}
+}
+
+// reset 不写响应，直接用 RST 关闭连接，客户端会看到 "connection reset by peer" 或者 EOF。
+func reset(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.SetLinger(0) // 关闭时发送 RST 而不是 FIN
+	}
+	conn.Close()
+}
@@ -0,0 +1,171 @@
+package fakeserver_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"CInG/fakeserver"
+)
+
+// 不复用连接：复用的连接被重置时 net/http 会悄悄重试幂等请求，测试就看不到重置了。
+var client = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+func get(t *testing.T, ctx context.Context, url string) (int, string, error) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
+
+func TestDefaults(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{Name: "open-weather-1", Latency: fakeserver.FixedLatency(20 * time.Millisecond)})
+	defer s.Close()
+
+	begin := time.Now()
+	code, body, err := get(t, context.Background(), s.URL+"/weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusOK || body != "open-weather-1:ok" {
+		t.Errorf("got %d %q, want 200 open-weather-1:ok", code, body)
+	}
+	if elapsed := time.Since(begin); elapsed < 20*time.Millisecond {
+		t.Errorf("responded after %v, want at least 20ms", elapsed)
+	}
+
+	rs := s.Requests()
+	if len(rs) != 1 || rs[0].Method != http.MethodGet || rs[0].Path != "/weather" {
+		t.Errorf("requests = %+v, want one GET /weather", rs)
+	}
+}
+
+func TestScript(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{
+		Name: "flaky",
+		Script: []fakeserver.Response{
+			{Status: http.StatusServiceUnavailable},
+			{Status: http.StatusTooManyRequests, Body: "slow down"},
+			{Reset: true},
+		},
+	})
+	defer s.Close()
+
+	want := []struct {
+		code int
+		body string
+	}{
+		{http.StatusServiceUnavailable, "flaky:ok"},
+		{http.StatusTooManyRequests, "slow down"},
+	}
+	for i, w := range want {
+		code, body, err := get(t, context.Background(), s.URL)
+		if err != nil || code != w.code || body != w.body {
+			t.Errorf("request %d: got %d %q %v, want %d %q", i, code, body, err, w.code, w.body)
+		}
+	}
+
+	if _, _, err := get(t, context.Background(), s.URL); err == nil {
+		t.Error("request 3: want a connection error from the reset")
+	}
+
+	// 脚本用完之后恢复正常。
+	if code, _, err := get(t, context.Background(), s.URL); err != nil || code != http.StatusOK {
+		t.Errorf("request 4: got %d %v, want 200", code, err)
+	}
+	if n := s.Count(); n != 4 {
+		t.Errorf("count = %d, want 4", n)
+	}
+}
+
+func TestErrorRate(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{ErrorRate: 0.3, ErrorStatus: http.StatusBadGateway, Seed: 1})
+	defer s.Close()
+
+	errs := 0
+	for range 200 {
+		code, _, err := get(t, context.Background(), s.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code == http.StatusBadGateway {
+			errs++
+		}
+	}
+	if errs < 30 || errs > 90 {
+		t.Errorf("%d of 200 requests failed, want about 60", errs)
+	}
+}
+
+func TestSeedIsReproducible(t *testing.T) {
+	run := func() []fakeserver.Response {
+		s := fakeserver.New(fakeserver.Config{
+			Latency:   fakeserver.UniformLatency(0, time.Millisecond),
+			ErrorRate: 0.5,
+			Seed:      42,
+		})
+		defer s.Close()
+		var rs []fakeserver.Response
+		for range 10 {
+			get(t, context.Background(), s.URL)
+		}
+		for _, r := range s.Requests() {
+			rs = append(rs, r.Response)
+		}
+		return rs
+	}
+
+	a, b := run(), run()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("response %d differs between runs: %+v vs %+v", i, a[i], b[i])
+		}
+	}
+}
+
+func TestSlowBody(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{
+		Body:       strings.Repeat("x", 10),
+		ChunkSize:  2,
+		ChunkDelay: 10 * time.Millisecond,
+	})
+	defer s.Close()
+
+	// 响应头马上就到，响应体要慢慢读完。
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Millisecond)
+	defer cancel()
+	if _, _, err := get(t, ctx, s.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the body read to time out", err)
+	}
+
+	code, body, err := get(t, context.Background(), s.URL)
+	if err != nil || code != http.StatusOK || len(body) != 10 {
+		t.Errorf("got %d %q %v, want the whole body", code, body, err)
+	}
+}
+
+func TestCanceledRequests(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{Latency: fakeserver.FixedLatency(time.Minute)})
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	get(t, ctx, s.URL)
+
+	deadline := time.Now().Add(time.Second)
+	for s.Canceled() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if s.Canceled() != 1 {
+		t.Errorf("canceled = %d, want the abandoned request recorded", s.Canceled())
+	}
+}
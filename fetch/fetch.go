@@ -0,0 +1,277 @@
+// Package fetch 把 other/gojingjin/33/go_currency_pattern_12.go 里的 fetchAPI 整理成更可靠的版本：
+// 可以配置 http.Client，幂等请求失败时按退避重试并遵守 Retry-After，限制响应体大小，
+// 并且用类型化的错误区分超时、HTTP状态码和响应体过大。所有等待都会随调用方的 ctx 结束。
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrTimeout 请求超时：调用方 ctx 的截止时间到了，或者单次请求超过了 WithAttemptTimeout。
+	// 调用方 ctx 到期时，返回的错误同时包装了 context.DeadlineExceeded。
+	ErrTimeout = errors.New("fetch: timeout")
+	// ErrTooLarge 响应体超过了 WithMaxBodySize。
+	ErrTooLarge = errors.New("fetch: response body too large")
+)
+
+// ErrStatus 服务器返回了非2xx的状态码。
+type ErrStatus struct {
+	URL  string
+	Code int
+}
+
+func (e *ErrStatus) Error() string {
+	return fmt.Sprintf("fetch: %s: status %d %s", e.URL, e.Code, http.StatusText(e.Code))
+}
+
+// Response 一次成功的请求，Body 已经读完。
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Attempts   int // 一共请求了几次
+}
+
+// Fetcher 可以在多个 goroutine 里同时使用。
+type Fetcher struct {
+	client         *http.Client
+	maxBody        int64
+	attemptTimeout time.Duration
+
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+type Option func(*Fetcher)
+
+// WithClient 使用自己的 http.Client，默认 http.DefaultClient。
+func WithClient(c *http.Client) Option {
+	return func(f *Fetcher) {
+		f.client = c
+	}
+}
+
+// WithMaxBodySize 响应体最多读多少字节，超过时返回 ErrTooLarge，默认10MB。
+func WithMaxBodySize(n int64) Option {
+	return func(f *Fetcher) {
+		f.maxBody = n
+	}
+}
+
+// WithAttemptTimeout 单次请求的超时时间，超时之后可以重试。默认0，只受调用方 ctx 限制。
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(f *Fetcher) {
+		f.attemptTimeout = d
+	}
+}
+
+// WithRetry 幂等请求最多请求 maxAttempts 次（包括第一次）；第n次重试前等待 [0, baseDelay*2^(n-1)) 之间的随机时间，
+// 不超过 maxDelay。服务器返回 Retry-After 时至少等那么久。默认3次、100ms、5秒。
+func WithRetry(maxAttempts int, baseDelay, maxDelay time.Duration) Option {
+	return func(f *Fetcher) {
+		f.maxAttempts = max(maxAttempts, 1)
+		f.baseDelay = baseDelay
+		f.maxDelay = maxDelay
+	}
+}
+
+func New(opts ...Option) *Fetcher {
+	f := &Fetcher{
+		client:      http.DefaultClient,
+		maxBody:     10 << 20,
+		maxAttempts: 3,
+		baseDelay:   100 * time.Millisecond,
+		maxDelay:    5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Get 请求 url，返回响应体。
+func (f *Fetcher) Get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: %w", err)
+	}
+	resp, err := f.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Do 发送 req，使用 req.Context() 作为调用方的 ctx。只有幂等的请求（GET、HEAD、OPTIONS、PUT、DELETE）
+// 会重试；带请求体的请求需要设置 req.GetBody（http.NewRequest 会为常见的 body 类型设置好）。
+// 网络错误、单次请求超时，以及 429、500、502、503、504 状态码会重试；其余非2xx状态码直接返回 *ErrStatus。
+func (f *Fetcher) Do(req *http.Request) (*Response, error) {
+	ctx := req.Context()
+	attempts := 1
+	if idempotent(req.Method) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil) {
+		attempts = f.maxAttempts
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var resp *Response
+		var retryAfter time.Duration
+		resp, retryAfter, err = f.do(req, attempt)
+		if err == nil {
+			resp.Attempts = attempt
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctxErr(ctx, err)
+		}
+		if attempt >= attempts || !retryable(err) {
+			return nil, err
+		}
+
+		wait := max(f.backoff(attempt), retryAfter)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, err // 等不到服务器要求的时间了，直接返回最后一次的错误
+		}
+		if sleep(ctx, wait) != nil {
+			return nil, ctxErr(ctx, err)
+		}
+	}
+}
+
+// do 请求一次。返回的 time.Duration 是服务器通过 Retry-After 要求等待的时间。
+func (f *Fetcher) do(req *http.Request, attempt int) (*Response, time.Duration, error) {
+	ctx := req.Context()
+	if f.attemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.attemptTimeout)
+		defer cancel()
+	}
+
+	r := req.Clone(ctx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, 0, fmt.Errorf("fetch: %w", err)
+		}
+		r.Body = body
+	}
+
+	resp, err := f.client.Do(r)
+	if err != nil {
+		return nil, 0, wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, f.maxBody)) // 读完才能复用连接
+		return nil, retryAfter(resp.Header.Get("Retry-After")), &ErrStatus{URL: req.URL.String(), Code: resp.StatusCode}
+	}
+
+	if resp.ContentLength > f.maxBody {
+		return nil, 0, fmt.Errorf("%w: Content-Length %d > %d", ErrTooLarge, resp.ContentLength, f.maxBody)
+	}
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(io.LimitReader(resp.Body, f.maxBody+1))
+	if err != nil {
+		return nil, 0, wrap(err)
+	}
+	if n > f.maxBody {
+		return nil, 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, f.maxBody)
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: buf.Bytes()}, 0, nil
+}
+
+// wrap 把超时类的错误包装成 ErrTimeout，其余的加上 fetch 前缀。
+func wrap(err error) error {
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return fmt.Errorf("fetch: %w", err)
+}
+
+func (f *Fetcher) backoff(retry int) time.Duration {
+	d := f.baseDelay << (retry - 1)
+	if d <= 0 || (f.maxDelay > 0 && d > f.maxDelay) {
+		d = f.maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) // 全抖动，避免大量客户端同时重试
+}
+
+// ctxErr 调用方的 ctx 结束之后返回的错误：到期算超时，被取消就返回 context.Canceled。
+func ctxErr(ctx context.Context, last error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		if errors.Is(last, ErrTimeout) {
+			return last
+		}
+		return fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	}
+	return fmt.Errorf("fetch: %w", ctx.Err())
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryable(err error) bool {
+	if errors.Is(err, ErrTooLarge) {
+		return false
+	}
+	var se *ErrStatus
+	if errors.As(err, &se) {
+		switch se.Code {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return true // 网络错误和单次请求超时
+}
+
+// retryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式。
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(s)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
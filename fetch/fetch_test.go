@@ -0,0 +1,221 @@
+package fetch_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"CInG/fakeserver"
+	"CInG/fetch"
+)
+
+// 不复用连接：复用的连接被重置时 net/http 会悄悄重试幂等请求，测试就看不到重置了。
+var client = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+func newFetcher(opts ...fetch.Option) *fetch.Fetcher {
+	return fetch.New(append([]fetch.Option{
+		fetch.WithClient(client),
+		fetch.WithRetry(3, time.Millisecond, 10*time.Millisecond),
+	}, opts...)...)
+}
+
+func TestRetriesTransientErrors(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{
+		Name: "api",
+		Script: []fakeserver.Response{
+			{Status: http.StatusServiceUnavailable},
+			{Reset: true},
+		},
+	})
+	defer s.Close()
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, s.URL, nil)
+	resp, err := newFetcher().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "api:ok" || resp.Attempts != 3 {
+		t.Errorf("got %q after %d attempts, want api:ok after 3", resp.Body, resp.Attempts)
+	}
+	if n := s.Count(); n != 3 {
+		t.Errorf("server saw %d requests, want 3", n)
+	}
+}
+
+func TestGiveUpAfterMaxAttempts(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{Status: http.StatusBadGateway})
+	defer s.Close()
+
+	_, err := newFetcher().Get(context.Background(), s.URL)
+	var se *fetch.ErrStatus
+	if !errors.As(err, &se) || se.Code != http.StatusBadGateway {
+		t.Fatalf("err = %v, want *ErrStatus 502", err)
+	}
+	if n := s.Count(); n != 3 {
+		t.Errorf("server saw %d requests, want 3", n)
+	}
+}
+
+func TestClientErrorNotRetried(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{Status: http.StatusNotFound})
+	defer s.Close()
+
+	_, err := newFetcher().Get(context.Background(), s.URL)
+	var se *fetch.ErrStatus
+	if !errors.As(err, &se) || se.Code != http.StatusNotFound {
+		t.Fatalf("err = %v, want *ErrStatus 404", err)
+	}
+	if n := s.Count(); n != 1 {
+		t.Errorf("server saw %d requests, want 1", n)
+	}
+}
+
+func TestOnlyIdempotentRequestsRetried(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		want   int
+	}{
+		{http.MethodPost, 1},
+		{http.MethodPut, 2},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			s := fakeserver.New(fakeserver.Config{Script: []fakeserver.Response{{Status: http.StatusServiceUnavailable}}})
+			defer s.Close()
+
+			req, _ := http.NewRequest(tc.method, s.URL, strings.NewReader("order-1"))
+			newFetcher().Do(req)
+
+			rs := s.Requests()
+			if len(rs) != tc.want {
+				t.Fatalf("server saw %d requests, want %d", len(rs), tc.want)
+			}
+			for i, r := range rs {
+				if string(r.Body) != "order-1" {
+					t.Errorf("request %d body = %q, want the body resent", i, r.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var hits atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	begin := time.Now()
+	body, err := newFetcher().Get(context.Background(), s.URL)
+	if err != nil || string(body) != "ok" {
+		t.Fatalf("got %q %v, want ok", body, err)
+	}
+	if elapsed := time.Since(begin); elapsed < time.Second {
+		t.Errorf("retried after %v, want Retry-After 1s honored", elapsed)
+	}
+}
+
+func TestRetryAfterBeyondDeadline(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	begin := time.Now()
+	_, err := newFetcher().Get(ctx, s.URL)
+	var se *fetch.ErrStatus
+	if !errors.As(err, &se) || se.Code != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want *ErrStatus 503", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+		t.Errorf("returned after %v, want no wait that cannot finish before the deadline", elapsed)
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		chunkSize int // 大于0时分块写，没有 Content-Length
+	}{
+		{"content-length", 0},
+		{"chunked", 8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := fakeserver.New(fakeserver.Config{Body: strings.Repeat("x", 100), ChunkSize: tc.chunkSize})
+			defer s.Close()
+
+			_, err := newFetcher(fetch.WithMaxBodySize(64)).Get(context.Background(), s.URL)
+			if !errors.Is(err, fetch.ErrTooLarge) {
+				t.Fatalf("err = %v, want ErrTooLarge", err)
+			}
+			if n := s.Count(); n != 1 {
+				t.Errorf("server saw %d requests, want 1", n)
+			}
+
+			body, err := newFetcher(fetch.WithMaxBodySize(100)).Get(context.Background(), s.URL)
+			if err != nil || len(body) != 100 {
+				t.Errorf("got %d bytes %v, want a body of exactly the limit accepted", len(body), err)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{Latency: fakeserver.FixedLatency(time.Minute)})
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	_, err := newFetcher().Get(ctx, s.URL)
+	if !errors.Is(err, fetch.ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want ErrTimeout wrapping context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("returned after %v, want right after the deadline", elapsed)
+	}
+}
+
+func TestAttemptTimeoutRetried(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{Name: "api", Script: []fakeserver.Response{{Latency: time.Minute}}})
+	defer s.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	resp, err := newFetcher(fetch.WithAttemptTimeout(50 * time.Millisecond)).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "api:ok" || resp.Attempts != 2 {
+		t.Errorf("got %q after %d attempts, want api:ok after 2", resp.Body, resp.Attempts)
+	}
+}
+
+func TestCanceledDuringBackoff(t *testing.T) {
+	s := fakeserver.New(fakeserver.Config{Status: http.StatusServiceUnavailable})
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	f := newFetcher(fetch.WithRetry(10, time.Hour, time.Hour))
+	begin := time.Now()
+	_, err := f.Get(ctx, s.URL)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, fetch.ErrTimeout) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("returned after %v, want right after cancel", elapsed)
+	}
+}
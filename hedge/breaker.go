@@ -0,0 +1,168 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 所有后端的熔断器都是打开的，没有发出任何请求。
+var ErrCircuitOpen = errors.New("hedge: all circuits open")
+
+// State 熔断器状态。
+type State int
+
+const (
+	Closed   State = iota // 正常请求
+	Open                  // 不再请求，等 OpenTimeout 之后进入半开
+	HalfOpen              // 放少量试探请求过去，成功就关闭，失败就重新打开
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// StateChange 熔断器状态变化事件。
+type StateChange struct {
+	Backend  string
+	From, To State
+	At       time.Time
+}
+
+// BreakerPolicy 熔断策略。
+type BreakerPolicy struct {
+	FailureThreshold int           // 连续失败多少次打开，默认5
+	SlowThreshold    time.Duration // 超过这个时间才返回（或者被取消时已经超过）的请求也算失败，0表示不看延迟
+	OpenTimeout      time.Duration // 打开多久之后进入半开，默认5秒
+	HalfOpenProbes   int           // 半开时同时放过去的试探请求数，全部成功才关闭，默认1
+
+	// OnStateChange 状态变化时调用，不要在里面阻塞。
+	OnStateChange func(StateChange)
+}
+
+// Breaker 一个后端的熔断器，可以在多个 goroutine 里同时使用。
+type Breaker struct {
+	name   string
+	policy BreakerPolicy
+
+	mu        sync.Mutex
+	state     State
+	failures  int // Closed：连续失败次数
+	openedAt  time.Time
+	probes    int    // HalfOpen：还没有结果的试探请求数
+	successes int    // HalfOpen：成功的试探请求数
+	gen       uint64 // 每次状态变化加1，用来认出在之前的状态下放过去的请求
+}
+
+func NewBreaker(name string, policy BreakerPolicy) *Breaker {
+	if policy.FailureThreshold < 1 {
+		policy.FailureThreshold = 5
+	}
+	if policy.OpenTimeout <= 0 {
+		policy.OpenTimeout = 5 * time.Second
+	}
+	policy.HalfOpenProbes = max(policy.HalfOpenProbes, 1)
+	return &Breaker{name: name, policy: policy}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow 判断现在能不能请求这个后端。ok 为 true 时调用方必须在请求结束后把 gen 原样交给 Record。
+func (b *Breaker) Allow() (gen uint64, ok bool) {
+	b.mu.Lock()
+	var change *StateChange
+	defer func() {
+		b.mu.Unlock()
+		b.notify(change)
+	}()
+
+	if b.state == Open {
+		if time.Since(b.openedAt) < b.policy.OpenTimeout {
+			return 0, false
+		}
+		change = b.setState(HalfOpen)
+	}
+	if b.state == HalfOpen {
+		if b.probes >= b.policy.HalfOpenProbes {
+			return 0, false
+		}
+		b.probes++
+	}
+	return b.gen, true
+}
+
+// Record 记录一次请求的结果，gen 是 Allow 返回的值。err 为 ctx 被取消（对冲时输掉的请求）且没有超过 SlowThreshold 时，
+// 这次请求不算成功也不算失败。状态在请求期间变过的话结果被忽略：比如关闭时发出的慢请求
+// 在半开之后才返回，它不是试探请求，不能让熔断器关闭，也不能占掉试探请求的名额。
+func (b *Breaker) Record(gen uint64, err error, latency time.Duration) {
+	slow := b.policy.SlowThreshold > 0 && latency >= b.policy.SlowThreshold
+	aborted := errors.Is(err, context.Canceled) && !slow
+	failed := err != nil || slow
+
+	b.mu.Lock()
+	var change *StateChange
+	defer func() {
+		b.mu.Unlock()
+		b.notify(change)
+	}()
+
+	if gen != b.gen {
+		return // 在之前的状态下放过去的请求；Open 时不放请求，所以下面不用处理 Open
+	}
+	switch b.state {
+	case Closed:
+		switch {
+		case aborted:
+		case failed:
+			b.failures++
+			if b.failures >= b.policy.FailureThreshold {
+				change = b.setState(Open)
+			}
+		default:
+			b.failures = 0
+		}
+	case HalfOpen:
+		b.probes = max(b.probes-1, 0)
+		switch {
+		case aborted:
+		case failed:
+			change = b.setState(Open)
+		default:
+			b.successes++
+			if b.successes >= b.policy.HalfOpenProbes {
+				change = b.setState(Closed)
+			}
+		}
+	}
+}
+
+// setState 调用方持有 b.mu。
+func (b *Breaker) setState(to State) *StateChange {
+	change := &StateChange{Backend: b.name, From: b.state, To: to, At: time.Now()}
+	b.state = to
+	b.gen++
+	b.failures, b.probes, b.successes = 0, 0, 0
+	if to == Open {
+		b.openedAt = change.At
+	}
+	return change
+}
+
+func (b *Breaker) notify(change *StateChange) {
+	if change != nil && b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(*change)
+	}
+}
@@ -0,0 +1,203 @@
+package hedge_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"CInG/hedge"
+)
+
+// events 收集熔断器状态变化。
+type events struct {
+	mu      sync.Mutex
+	changes []hedge.StateChange
+}
+
+func (e *events) record(c hedge.StateChange) {
+	e.mu.Lock()
+	e.changes = append(e.changes, c)
+	e.mu.Unlock()
+}
+
+func (e *events) states() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var s []string
+	for _, c := range e.changes {
+		s = append(s, c.From.String()+"->"+c.To.String())
+	}
+	return s
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBreakerStates(t *testing.T) {
+	var ev events
+	b := hedge.NewBreaker("open-weather-2", hedge.BreakerPolicy{
+		FailureThreshold: 2,
+		OpenTimeout:      30 * time.Millisecond,
+		OnStateChange:    ev.record,
+	})
+	boom := errors.New("boom")
+
+	for range 2 {
+		gen, ok := b.Allow()
+		if !ok {
+			t.Fatal("closed breaker rejected a request")
+		}
+		b.Record(gen, boom, time.Millisecond)
+	}
+	if _, ok := b.Allow(); b.State() != hedge.Open || ok {
+		t.Fatalf("state = %v, want open and rejecting", b.State())
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	probe, ok := b.Allow()
+	if !ok {
+		t.Fatal("breaker did not let a probe through after OpenTimeout")
+	}
+	if _, ok := b.Allow(); ok {
+		t.Error("half-open breaker let a second probe through")
+	}
+	b.Record(probe, nil, time.Millisecond)
+	if b.State() != hedge.Closed {
+		t.Errorf("state = %v after a successful probe, want closed", b.State())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if got := ev.states(); !equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestBreakerHalfOpenFailure(t *testing.T) {
+	b := hedge.NewBreaker("a", hedge.BreakerPolicy{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond})
+	gen, _ := b.Allow()
+	b.Record(gen, errors.New("boom"), 0)
+
+	time.Sleep(20 * time.Millisecond)
+	gen, _ = b.Allow()
+	b.Record(gen, errors.New("still down"), 0)
+	if b.State() != hedge.Open {
+		t.Errorf("state = %v after a failed probe, want open", b.State())
+	}
+}
+
+func TestBreakerIgnoresCanceledLosers(t *testing.T) {
+	b := hedge.NewBreaker("a", hedge.BreakerPolicy{FailureThreshold: 1, SlowThreshold: time.Second})
+	gen, _ := b.Allow()
+	b.Record(gen, context.Canceled, 10*time.Millisecond) // 对冲输掉，但并不慢
+	if b.State() != hedge.Closed {
+		t.Fatalf("state = %v, a fast canceled request must not count as failure", b.State())
+	}
+
+	gen, _ = b.Allow()
+	b.Record(gen, context.Canceled, 2*time.Second) // 被取消时已经很慢了
+	if b.State() != hedge.Open {
+		t.Errorf("state = %v, a slow canceled request must count as failure", b.State())
+	}
+}
+
+func TestBreakerIgnoresRequestsFromEarlierState(t *testing.T) {
+	b := hedge.NewBreaker("a", hedge.BreakerPolicy{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond})
+
+	// 关闭时发出的慢请求，等它返回时熔断器已经打开又进入了半开。
+	slow, _ := b.Allow()
+	gen, _ := b.Allow()
+	b.Record(gen, errors.New("boom"), 0)
+	time.Sleep(20 * time.Millisecond)
+	probe, ok := b.Allow()
+	if !ok || b.State() != hedge.HalfOpen {
+		t.Fatalf("state = %v, want half-open with a probe let through", b.State())
+	}
+
+	b.Record(slow, nil, time.Second)
+	if b.State() != hedge.HalfOpen {
+		t.Fatalf("state = %v, the slow request is not a probe and must not close the breaker", b.State())
+	}
+	if _, ok := b.Allow(); ok {
+		t.Fatal("the slow request freed the probe slot while the real probe is still outstanding")
+	}
+
+	b.Record(probe, nil, time.Millisecond)
+	if b.State() != hedge.Closed {
+		t.Errorf("state = %v after the probe succeeded, want closed", b.State())
+	}
+}
+
+func TestHedgedClientSkipsOpenBreaker(t *testing.T) {
+	primary := newBackend(t, "primary", 0, http.StatusInternalServerError)
+	backup := newBackend(t, "backup", 0, http.StatusOK)
+
+	var ev events
+	c := hedge.NewHedgedClient([]hedge.Backend{primary.Backend, backup.Backend},
+		hedge.WithHedgeDelay(time.Minute),
+		hedge.WithBreaker(hedge.BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute, OnStateChange: ev.record}))
+
+	for range 3 {
+		resp, err := c.Get(context.Background(), "/weather")
+		if err != nil || resp.Backend != "backup" {
+			t.Fatalf("Get() = %+v, %v, want backup", resp, err)
+		}
+	}
+	if n := primary.Count(); n != 2 {
+		t.Errorf("primary got %d requests, want 2: the open breaker must skip it", n)
+	}
+	if c.Breaker("primary").State() != hedge.Open {
+		t.Errorf("primary breaker = %v, want open", c.Breaker("primary").State())
+	}
+	if got := ev.states(); !equal(got, []string{"closed->open"}) {
+		t.Errorf("events = %v, want [closed->open]", got)
+	}
+}
+
+func TestHedgedClientAllOpen(t *testing.T) {
+	down := newBackend(t, "down", 0, http.StatusBadGateway)
+	c := hedge.NewHedgedClient([]hedge.Backend{down.Backend},
+		hedge.WithBreaker(hedge.BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute}))
+
+	c.Get(context.Background(), "/")
+	if _, err := c.Get(context.Background(), "/"); !errors.Is(err, hedge.ErrCircuitOpen) {
+		t.Errorf("err = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestHedgedClientSlowBackendTrips(t *testing.T) {
+	// 就像 open-weather-2：总是很慢，每次都在对冲中输掉。
+	slow := newBackend(t, "slow", time.Minute, http.StatusOK)
+	fast := newBackend(t, "fast", 0, http.StatusOK)
+
+	c := hedge.NewHedgedClient([]hedge.Backend{slow.Backend, fast.Backend},
+		hedge.WithHedgeDelay(30*time.Millisecond),
+		hedge.WithBreaker(hedge.BreakerPolicy{FailureThreshold: 2, SlowThreshold: 20 * time.Millisecond, OpenTimeout: time.Minute}))
+
+	for range 2 {
+		if _, err := c.Get(context.Background(), "/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 输掉的请求在 Get 返回后才记录结果，等它们落定。
+	deadline := time.Now().Add(time.Second)
+	for c.Breaker("slow").State() != hedge.Open && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err := c.Get(context.Background(), "/")
+	if err != nil || resp.Backend != "fast" || resp.Attempts != 1 {
+		t.Errorf("Get() = %+v, %v, want fast on the first attempt", resp, err)
+	}
+}
@@ -0,0 +1,280 @@
+// Package hedge 把 other/gojingjin/33/6_timeout_and_cancel_model.go 里的 first() 整理成可以复用的对冲请求客户端：
+// 先只请求主后端，过一段时间（固定的对冲延迟或者历史延迟的某个分位数）还没有结果才请求备用后端，
+// 谁先成功用谁的结果，其余请求立即取消。
+package hedge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend 一个后端。URL 是基础地址，请求的 path 拼在它后面。
+type Backend struct {
+	Name string
+	URL  string
+}
+
+// StatusError 后端返回了非2xx的状态码。
+type StatusError struct {
+	Backend    string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("hedge: %s: status %d %s", e.Backend, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// BackendError 请求某个后端失败（网络错误、读响应失败等）。
+type BackendError struct {
+	Backend string
+	Err     error
+}
+
+func (e *BackendError) Error() string {
+	return "hedge: " + e.Backend + ": " + e.Err.Error()
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// Response 获胜后端的响应，Body 已经读完。
+type Response struct {
+	Backend    string // 获胜的后端
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Latency    time.Duration // 获胜的那个请求自己的耗时
+	Attempts   int           // 一共请求了几个后端
+}
+
+const (
+	latencyWindow     = 100 // 计算分位数时用最近多少个延迟
+	minLatencySamples = 10  // 样本少于这个数时用固定的对冲延迟
+)
+
+// HedgedClient 对冲请求客户端，可以在多个 goroutine 里同时使用。
+// 对冲会把同一个请求发给多个后端，只应该用于幂等的请求。
+type HedgedClient struct {
+	backends   []Backend
+	client     *http.Client
+	hedgeDelay time.Duration
+	percentile float64
+	breakers   []*Breaker // 和 backends 一一对应，没有打开熔断时为nil
+
+	mu        sync.Mutex
+	latencies []time.Duration // 环形缓冲，最近 latencyWindow 个成功请求的延迟
+	next      int
+}
+
+type Option func(*HedgedClient)
+
+// WithHTTPClient 使用自己的 http.Client，默认 http.DefaultClient。
+func WithHTTPClient(c *http.Client) Option {
+	return func(hc *HedgedClient) {
+		hc.client = c
+	}
+}
+
+// WithHedgeDelay 请求一个后端之后，等多久还没有结果就请求下一个，默认100ms。
+func WithHedgeDelay(d time.Duration) Option {
+	return func(hc *HedgedClient) {
+		hc.hedgeDelay = d
+	}
+}
+
+// WithHedgePercentile 对冲延迟取最近成功请求延迟的 p 分位数（0-1，比如0.95），
+// 样本不够时用 WithHedgeDelay 的值。
+func WithHedgePercentile(p float64) Option {
+	return func(hc *HedgedClient) {
+		hc.percentile = min(max(p, 0), 1)
+	}
+}
+
+// WithBreaker 给每个后端加一个熔断器：熔断器打开的后端不会被请求，直接跳到下一个后端。
+func WithBreaker(policy BreakerPolicy) Option {
+	return func(hc *HedgedClient) {
+		hc.breakers = make([]*Breaker, len(hc.backends))
+		for i, b := range hc.backends {
+			hc.breakers[i] = NewBreaker(b.Name, policy)
+		}
+	}
+}
+
+// NewHedgedClient backends 的第一个是主后端，其余按顺序作为备用后端。
+func NewHedgedClient(backends []Backend, opts ...Option) *HedgedClient {
+	hc := &HedgedClient{
+		backends:   backends,
+		client:     http.DefaultClient,
+		hedgeDelay: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(hc)
+	}
+	return hc
+}
+
+// Breaker 返回后端的熔断器，没有这个后端或者没有打开熔断时返回nil。
+func (hc *HedgedClient) Breaker(name string) *Breaker {
+	for i, b := range hc.backends {
+		if b.Name == name && hc.breakers != nil {
+			return hc.breakers[i]
+		}
+	}
+	return nil
+}
+
+// HedgeDelay 返回当前使用的对冲延迟。
+func (hc *HedgedClient) HedgeDelay() time.Duration {
+	if hc.percentile <= 0 {
+		return hc.hedgeDelay
+	}
+
+	hc.mu.Lock()
+	if len(hc.latencies) < minLatencySamples {
+		hc.mu.Unlock()
+		return hc.hedgeDelay
+	}
+	sorted := slices.Clone(hc.latencies)
+	hc.mu.Unlock()
+
+	slices.Sort(sorted)
+	i := int(hc.percentile * float64(len(sorted)-1))
+	return sorted[i]
+}
+
+func (hc *HedgedClient) observe(d time.Duration) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if len(hc.latencies) < latencyWindow {
+		hc.latencies = append(hc.latencies, d)
+		return
+	}
+	hc.latencies[hc.next] = d
+	hc.next = (hc.next + 1) % latencyWindow
+}
+
+type attempt struct {
+	resp *Response
+	err  error
+}
+
+// Get 对冲地请求 path。先请求主后端；每过一个对冲延迟还没有成功的结果，就再请求下一个备用后端；
+// 某个后端失败时不等对冲延迟，立即请求下一个；熔断器打开的后端被跳过。第一个成功（2xx）的响应获胜，其余请求被取消。
+// 所有后端都失败时，返回的错误合并了每个后端的 *StatusError 或 *BackendError。
+func (hc *HedgedClient) Get(ctx context.Context, path string) (*Response, error) {
+	if len(hc.backends) == 0 {
+		return nil, errors.New("hedge: no backends")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // 取消输掉的请求
+
+	results := make(chan attempt, len(hc.backends)) // 带缓冲：输掉的请求返回时不会阻塞
+	next, fired, inflight := 0, 0, 0
+	// fire 请求下一个熔断器没有打开的后端，没有这样的后端时返回 false。
+	fire := func() bool {
+		for ; next < len(hc.backends); next++ {
+			var br *Breaker
+			var gen uint64
+			if hc.breakers != nil {
+				br = hc.breakers[next]
+				var ok bool
+				if gen, ok = br.Allow(); !ok {
+					continue
+				}
+			}
+
+			b := hc.backends[next]
+			next++
+			fired++
+			inflight++
+			go hc.try(ctx, b, br, gen, path, results)
+			return true
+		}
+		return false
+	}
+
+	delay := hc.HedgeDelay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var errs []error
+	if !fire() {
+		return nil, ErrCircuitOpen
+	}
+	for inflight > 0 {
+		var hedge <-chan time.Time
+		if next < len(hc.backends) {
+			hedge = timer.C
+		}
+
+		select {
+		case a := <-results:
+			inflight--
+			if a.err == nil {
+				a.resp.Attempts = fired
+				hc.observe(a.resp.Latency)
+				return a.resp, nil
+			}
+			errs = append(errs, a.err)
+			if fire() {
+				timer.Reset(delay)
+			}
+		case <-hedge:
+			fire()
+			timer.Reset(delay)
+		case <-ctx.Done():
+			return nil, errors.Join(append([]error{ctx.Err()}, errs...)...)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// try 请求一个后端，把结果记到熔断器（不为nil时）里，再送到 results。gen 是 br.Allow 返回的值。
+func (hc *HedgedClient) try(ctx context.Context, b Backend, br *Breaker, gen uint64, path string, results chan<- attempt) {
+	start := time.Now()
+	resp, err := hc.get(ctx, b, path)
+	if br != nil {
+		br.Record(gen, err, time.Since(start))
+	}
+	results <- attempt{resp: resp, err: err}
+}
+
+func (hc *HedgedClient) get(ctx context.Context, b Backend, path string) (*Response, error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(b.URL, "/")+path, nil)
+	if err != nil {
+		return nil, &BackendError{Backend: b.Name, Err: err}
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return nil, &BackendError{Backend: b.Name, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, resp.Body) // 读完才能复用连接
+		return nil, &StatusError{Backend: b.Name, StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &BackendError{Backend: b.Name, Err: err}
+	}
+	return &Response{
+		Backend:    b.Name,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Latency:    time.Since(start),
+	}, nil
+}
@@ -0,0 +1,135 @@
+package hedge_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"CInG/fakeserver"
+	"CInG/hedge"
+)
+
+// backend 一个测试后端：等待 delay 之后返回 status。
+type backend struct {
+	*fakeserver.Server
+	hedge.Backend
+}
+
+func newBackend(t *testing.T, name string, delay time.Duration, status int) *backend {
+	srv := fakeserver.New(fakeserver.Config{Name: name, Latency: fakeserver.FixedLatency(delay), Status: status})
+	t.Cleanup(srv.Close)
+	return &backend{Server: srv, Backend: hedge.Backend{Name: name, URL: srv.URL}}
+}
+
+func TestHedgedClientPrimaryFast(t *testing.T) {
+	primary := newBackend(t, "primary", 0, http.StatusOK)
+	backup := newBackend(t, "backup", 0, http.StatusOK)
+
+	c := hedge.NewHedgedClient([]hedge.Backend{primary.Backend, backup.Backend}, hedge.WithHedgeDelay(200*time.Millisecond))
+	resp, err := c.Get(context.Background(), "/weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Backend != "primary" || string(resp.Body) != "primary:ok" || resp.Attempts != 1 {
+		t.Errorf("resp = %+v, want primary after 1 attempt", resp)
+	}
+	if backup.Count() != 0 {
+		t.Errorf("backup got %d requests, want none before the hedge delay", backup.Count())
+	}
+}
+
+func TestHedgedClientHedges(t *testing.T) {
+	primary := newBackend(t, "primary", time.Minute, http.StatusOK)
+	backup := newBackend(t, "backup", 0, http.StatusOK)
+
+	c := hedge.NewHedgedClient([]hedge.Backend{primary.Backend, backup.Backend}, hedge.WithHedgeDelay(20*time.Millisecond))
+	begin := time.Now()
+	resp, err := c.Get(context.Background(), "/weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Backend != "backup" || resp.Attempts != 2 {
+		t.Errorf("resp = %+v, want backup after 2 attempts", resp)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Get took %v, want about the hedge delay", elapsed)
+	}
+
+	// 输掉的主后端请求要被取消，而不是一直挂着。
+	deadline := time.Now().Add(time.Second)
+	for primary.Canceled() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if primary.Canceled() != 1 {
+		t.Error("losing request to primary was not canceled")
+	}
+}
+
+func TestHedgedClientFailover(t *testing.T) {
+	primary := newBackend(t, "primary", 0, http.StatusServiceUnavailable)
+	backup := newBackend(t, "backup", 0, http.StatusOK)
+
+	// 对冲延迟很长，主后端失败时要立即请求备用后端。
+	c := hedge.NewHedgedClient([]hedge.Backend{primary.Backend, backup.Backend}, hedge.WithHedgeDelay(time.Minute))
+	resp, err := c.Get(context.Background(), "/weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Backend != "backup" {
+		t.Errorf("winner = %s, want backup", resp.Backend)
+	}
+}
+
+func TestHedgedClientAllFail(t *testing.T) {
+	a := newBackend(t, "a", 0, http.StatusInternalServerError)
+	b := newBackend(t, "b", 0, http.StatusNotFound)
+
+	c := hedge.NewHedgedClient([]hedge.Backend{a.Backend, b.Backend}, hedge.WithHedgeDelay(time.Millisecond))
+	_, err := c.Get(context.Background(), "/weather")
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("err = %v, want the joined errors of both backends", err)
+	}
+
+	codes := make(map[string]int)
+	for _, e := range joined.Unwrap() {
+		var se *hedge.StatusError
+		if errors.As(e, &se) {
+			codes[se.Backend] = se.StatusCode
+		}
+	}
+	if codes["a"] != http.StatusInternalServerError || codes["b"] != http.StatusNotFound {
+		t.Errorf("err = %v, want a status error from each backend", err)
+	}
+}
+
+func TestHedgedClientContext(t *testing.T) {
+	slow := newBackend(t, "slow", time.Minute, http.StatusOK)
+	c := hedge.NewHedgedClient([]hedge.Backend{slow.Backend})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Get(ctx, "/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestHedgedClientPercentile(t *testing.T) {
+	primary := newBackend(t, "primary", 0, http.StatusOK)
+	c := hedge.NewHedgedClient([]hedge.Backend{primary.Backend},
+		hedge.WithHedgeDelay(time.Second), hedge.WithHedgePercentile(0.9))
+
+	if d := c.HedgeDelay(); d != time.Second {
+		t.Errorf("delay without samples = %v, want the fixed delay", d)
+	}
+	for range 20 {
+		if _, err := c.Get(context.Background(), "/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := c.HedgeDelay(); d <= 0 || d > 200*time.Millisecond {
+		t.Errorf("delay after fast responses = %v, want the observed p90", d)
+	}
+}
@@ -0,0 +1,125 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNoQuorum 截止时间到了或者所有后端都返回了，仍然没有足够多的后端给出相同的结果。
+	ErrNoQuorum = errors.New("hedge: no quorum")
+	// ErrPartial 截止时间到了还有后端没有返回，结果只基于已经收到的响应。
+	ErrPartial = errors.New("hedge: partial results")
+)
+
+// MultiResult 同时请求多个后端时收到的结果。
+type MultiResult struct {
+	Responses []*Response // 成功的响应，按到达顺序排列
+	Errs      []error     // 失败的后端，*StatusError 或 *BackendError
+	Partial   bool        // 截止时间到了，还有后端没有返回
+}
+
+// QuorumResult Quorum 的结果。
+type QuorumResult struct {
+	MultiResult
+	Value  *Response // 达成一致的响应之一，没有达成一致时为nil
+	Agreed []string  // 给出一致结果的后端
+}
+
+// Quorum 同时请求所有后端（熔断器打开的除外），等到有 k 个后端的结果一致就返回，其余请求被取消。
+// key 决定两个响应是否一致，为nil时比较响应体。
+// 没能达成一致时返回 ErrNoQuorum，截止时间到了的话还同时包装了 ctx.Err()；两种情况都会带上已经收到的结果。
+func (hc *HedgedClient) Quorum(ctx context.Context, path string, k int, key func(*Response) string) (*QuorumResult, error) {
+	if key == nil {
+		key = func(r *Response) string { return string(r.Body) }
+	}
+
+	votes := make(map[string][]*Response)
+	var agreed []*Response
+	mr, err := hc.collect(ctx, path, func(r *Response) bool {
+		kv := key(r)
+		votes[kv] = append(votes[kv], r)
+		if len(votes[kv]) >= k {
+			agreed = votes[kv]
+			return true
+		}
+		return false
+	})
+
+	qr := &QuorumResult{MultiResult: *mr}
+	if agreed == nil {
+		return qr, errors.Join(ErrNoQuorum, err)
+	}
+	qr.Value = agreed[0]
+	for _, r := range agreed {
+		qr.Agreed = append(qr.Agreed, r.Backend)
+	}
+	return qr, nil
+}
+
+// Aggregate 同时请求所有后端（熔断器打开的除外），等所有后端返回之后把成功的响应交给 reduce 合并。
+// 截止时间到了还有后端没有返回时，用已经收到的响应调用 reduce，同时返回 reduce 的结果和 ErrPartial。
+// 一个成功的响应都没有时不调用 reduce。
+func Aggregate[T any](ctx context.Context, hc *HedgedClient, path string, reduce func([]*Response) (T, error)) (T, *MultiResult, error) {
+	var zero T
+	mr, err := hc.collect(ctx, path, nil)
+	if len(mr.Responses) == 0 {
+		return zero, mr, errors.Join(append([]error{errors.New("hedge: no successful responses"), err}, mr.Errs...)...)
+	}
+
+	v, rerr := reduce(mr.Responses)
+	if rerr != nil {
+		return zero, mr, rerr
+	}
+	if mr.Partial {
+		return v, mr, errors.Join(ErrPartial, err)
+	}
+	return v, mr, nil
+}
+
+// collect 同时请求所有熔断器没有打开的后端，直到 enough 返回 true、所有后端都返回或者 ctx 结束。
+// 返回的错误只有 ctx.Err()（截止时间到了）和 ErrCircuitOpen 两种。
+func (hc *HedgedClient) collect(ctx context.Context, path string, enough func(*Response) bool) (*MultiResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // 取消还没返回的请求
+
+	results := make(chan attempt, len(hc.backends)) // 带缓冲：被取消的请求返回时不会阻塞
+	inflight := 0
+	for i, b := range hc.backends {
+		var br *Breaker
+		var gen uint64
+		if hc.breakers != nil {
+			br = hc.breakers[i]
+			var ok bool
+			if gen, ok = br.Allow(); !ok {
+				continue
+			}
+		}
+		inflight++
+		go hc.try(ctx, b, br, gen, path, results)
+	}
+
+	mr := &MultiResult{}
+	fired := inflight
+	if inflight == 0 {
+		return mr, ErrCircuitOpen
+	}
+	for ; inflight > 0; inflight-- {
+		select {
+		case a := <-results:
+			if a.err != nil {
+				mr.Errs = append(mr.Errs, a.err)
+				continue
+			}
+			a.resp.Attempts = fired
+			mr.Responses = append(mr.Responses, a.resp)
+			if enough != nil && enough(a.resp) {
+				return mr, nil
+			}
+		case <-ctx.Done():
+			mr.Partial = true
+			return mr, ctx.Err()
+		}
+	}
+	return mr, nil
+}
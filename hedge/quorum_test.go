@@ -0,0 +1,124 @@
+package hedge_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"CInG/fakeserver"
+	"CInG/hedge"
+)
+
+// provider 一个返回固定温度的天气后端。
+func provider(t *testing.T, name, temp string, delay time.Duration) hedge.Backend {
+	srv := fakeserver.New(fakeserver.Config{Name: name, Body: temp, Latency: fakeserver.FixedLatency(delay)})
+	t.Cleanup(srv.Close)
+	return hedge.Backend{Name: name, URL: srv.URL}
+}
+
+func TestQuorum(t *testing.T) {
+	c := hedge.NewHedgedClient([]hedge.Backend{
+		provider(t, "a", "21", 0),
+		provider(t, "b", "35", 0), // 数据有问题的后端
+		provider(t, "c", "21", 20*time.Millisecond),
+		provider(t, "d", "21", time.Minute), // 不用等它
+	})
+
+	begin := time.Now()
+	qr, err := c.Quorum(context.Background(), "/weather", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(qr.Value.Body) != "21" || len(qr.Agreed) != 2 {
+		t.Errorf("quorum = %q agreed by %v, want 21 agreed by 2 backends", qr.Value.Body, qr.Agreed)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Quorum took %v, want it to return once 2 backends agree", elapsed)
+	}
+}
+
+func TestQuorumKey(t *testing.T) {
+	c := hedge.NewHedgedClient([]hedge.Backend{
+		provider(t, "a", "21.2", 0),
+		provider(t, "b", "20.8", 0),
+	})
+
+	// 四舍五入到整数之后一致就算一致。
+	round := func(r *hedge.Response) string {
+		f, _ := strconv.ParseFloat(string(r.Body), 64)
+		return strconv.Itoa(int(f + 0.5))
+	}
+	if _, err := c.Quorum(context.Background(), "/", 2, round); err != nil {
+		t.Errorf("err = %v, want 21.2 and 20.8 to agree after rounding", err)
+	}
+}
+
+func TestQuorumDeadline(t *testing.T) {
+	c := hedge.NewHedgedClient([]hedge.Backend{
+		provider(t, "a", "21", 0),
+		provider(t, "b", "21", time.Minute),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	qr, err := c.Quorum(ctx, "/", 2, nil)
+
+	if !errors.Is(err, hedge.ErrNoQuorum) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want ErrNoQuorum and DeadlineExceeded", err)
+	}
+	if !qr.Partial || len(qr.Responses) != 1 || qr.Responses[0].Backend != "a" {
+		t.Errorf("result = %+v, want the partial response from a", qr.MultiResult)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	c := hedge.NewHedgedClient([]hedge.Backend{
+		provider(t, "a", "20", 0),
+		provider(t, "b", "22", 10*time.Millisecond),
+		provider(t, "c", "24", 20*time.Millisecond),
+	})
+
+	mean := func(rs []*hedge.Response) (float64, error) {
+		sum := 0.0
+		for _, r := range rs {
+			f, err := strconv.ParseFloat(strings.TrimSpace(string(r.Body)), 64)
+			if err != nil {
+				return 0, err
+			}
+			sum += f
+		}
+		return sum / float64(len(rs)), nil
+	}
+
+	v, mr, err := hedge.Aggregate(context.Background(), c, "/weather", mean)
+	if err != nil || v != 22 || len(mr.Responses) != 3 {
+		t.Errorf("Aggregate() = %v, %d responses, %v, want mean 22 of 3", v, len(mr.Responses), err)
+	}
+}
+
+func TestAggregatePartial(t *testing.T) {
+	c := hedge.NewHedgedClient([]hedge.Backend{
+		provider(t, "a", "x", 0),
+		provider(t, "b", "y", time.Minute),
+	})
+	concat := func(rs []*hedge.Response) (string, error) {
+		var b strings.Builder
+		for _, r := range rs {
+			b.Write(r.Body)
+		}
+		return b.String(), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	v, mr, err := hedge.Aggregate(ctx, c, "/", concat)
+	if !errors.Is(err, hedge.ErrPartial) {
+		t.Errorf("err = %v, want ErrPartial", err)
+	}
+	if v != "x" || !mr.Partial {
+		t.Errorf("Aggregate() = %q partial=%v, want x from the responses before the deadline", v, mr.Partial)
+	}
+}
@@ -0,0 +1,125 @@
+package join
+
+import (
+	"context"
+	"errors"
+)
+
+// All 等待所有 future 成功，结果按传入的顺序排列。
+// 任何一个失败就立即返回它的错误，并取消其余的 future。
+// 取消返回的 Future 会取消所有传入的 future。
+func All[T any](fs ...*Future[T]) *Future[[]T] {
+	return Spawn(context.Background(), func(ctx context.Context) ([]T, error) {
+		stop := make(chan struct{})
+		defer close(stop)
+		done := completions(fs, stop)
+
+		values := make([]T, len(fs))
+		for range fs {
+			select {
+			case i := <-done:
+				v, err := fs[i].Await()
+				if err != nil {
+					cancelAll(fs)
+					return nil, err
+				}
+				values[i] = v
+			case <-ctx.Done():
+				cancelAll(fs)
+				return nil, ctx.Err()
+			}
+		}
+		return values, nil
+	})
+}
+
+// Any 返回第一个成功的结果，并取消其余的 future。
+// 全部失败时返回所有错误合并在一起的结果，顺序和传入的顺序一致。
+func Any[T any](fs ...*Future[T]) *Future[T] {
+	return Spawn(context.Background(), func(ctx context.Context) (T, error) {
+		stop := make(chan struct{})
+		defer close(stop)
+		done := completions(fs, stop)
+
+		var zero T
+		errs := make([]error, len(fs))
+		for range fs {
+			select {
+			case i := <-done:
+				v, err := fs[i].Await()
+				if err == nil {
+					cancelAll(fs)
+					return v, nil
+				}
+				errs[i] = err
+			case <-ctx.Done():
+				cancelAll(fs)
+				return zero, ctx.Err()
+			}
+		}
+		if len(fs) == 0 {
+			return zero, errors.New("join: Any of no futures")
+		}
+		return zero, errors.Join(errs...)
+	})
+}
+
+// Race 返回第一个结束的 future 的结果，不管成功还是失败，并取消其余的 future。
+func Race[T any](fs ...*Future[T]) *Future[T] {
+	return Spawn(context.Background(), func(ctx context.Context) (T, error) {
+		stop := make(chan struct{})
+		defer close(stop)
+		done := completions(fs, stop)
+
+		var zero T
+		if len(fs) == 0 {
+			return zero, errors.New("join: Race of no futures")
+		}
+		select {
+		case i := <-done:
+			cancelAll(fs)
+			return fs[i].Await()
+		case <-ctx.Done():
+			cancelAll(fs)
+			return zero, ctx.Err()
+		}
+	})
+}
+
+// AllSettled 等待所有 future 结束，按传入的顺序返回每一个的结果，返回的错误总是nil。
+// 取消返回的 Future 会取消所有传入的 future，然后仍然等它们结束。
+func AllSettled[T any](fs ...*Future[T]) *Future[[]Result[T]] {
+	return Spawn(context.Background(), func(ctx context.Context) ([]Result[T], error) {
+		go func() {
+			<-ctx.Done() // Spawn 在函数返回后也会取消 ctx，这个 goroutine 不会泄漏
+			cancelAll(fs)
+		}()
+
+		results := make([]Result[T], len(fs))
+		for i, f := range fs {
+			results[i].Value, results[i].Err = f.Await()
+		}
+		return results, nil
+	})
+}
+
+// completions 按结束的先后顺序送出 future 的下标。stop 关闭之后不再送出，等待的 goroutine 随之退出。
+func completions[T any](fs []*Future[T], stop <-chan struct{}) <-chan int {
+	c := make(chan int, len(fs)) // 带缓冲：发送永远不会阻塞
+	for i, f := range fs {
+		go func() {
+			select {
+			case <-f.Done():
+				c <- i
+			case <-stop:
+			}
+		}()
+	}
+	return c
+}
+
+func cancelAll[T any](fs []*Future[T]) {
+	for _, f := range fs {
+		f.Cancel()
+	}
+}
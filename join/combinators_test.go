@@ -0,0 +1,130 @@
+package join_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"CInG/join"
+)
+
+// after 在 d 之后返回 v 和 err；被取消时返回 ctx.Err()，并关闭 canceled（不为nil时）。
+func after[T any](d time.Duration, v T, err error, canceled chan struct{}) *join.Future[T] {
+	return join.Spawn(context.Background(), func(ctx context.Context) (T, error) {
+		select {
+		case <-time.After(d):
+			return v, err
+		case <-ctx.Done():
+			if canceled != nil {
+				close(canceled)
+			}
+			var zero T
+			return zero, ctx.Err()
+		}
+	})
+}
+
+func waitClosed(t *testing.T, c chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-c:
+	case <-time.After(time.Second):
+		t.Errorf("%s was not canceled", what)
+	}
+}
+
+func TestAll(t *testing.T) {
+	values, err := join.All(
+		after(30*time.Millisecond, "a", nil, nil),
+		after(10*time.Millisecond, "b", nil, nil),
+	).Await()
+	if err != nil || len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Errorf("All() = %v, %v, want [a b], nil", values, err)
+	}
+}
+
+func TestAllFailFast(t *testing.T) {
+	boom := errors.New("boom")
+	slow := make(chan struct{})
+
+	begin := time.Now()
+	_, err := join.All(
+		after(time.Minute, 1, nil, slow),
+		after(10*time.Millisecond, 2, boom, nil),
+	).Await()
+
+	if !errors.Is(err, boom) {
+		t.Errorf("err = %v, want boom", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+		t.Errorf("All returned after %v, want it to fail fast", elapsed)
+	}
+	waitClosed(t, slow, "slow future")
+}
+
+func TestAny(t *testing.T) {
+	slow := make(chan struct{})
+	v, err := join.Any(
+		after(5*time.Millisecond, "", errors.New("fast failure"), nil),
+		after(20*time.Millisecond, "winner", nil, nil),
+		after(time.Minute, "slow", nil, slow),
+	).Await()
+
+	if v != "winner" || err != nil {
+		t.Errorf("Any() = %q, %v, want winner, nil", v, err)
+	}
+	waitClosed(t, slow, "slow future")
+}
+
+func TestAnyAllFail(t *testing.T) {
+	e1, e2 := errors.New("e1"), errors.New("e2")
+	_, err := join.Any(
+		after(20*time.Millisecond, 0, e1, nil),
+		after(5*time.Millisecond, 0, e2, nil),
+	).Await()
+	if !errors.Is(err, e1) || !errors.Is(err, e2) {
+		t.Errorf("err = %v, want e1 and e2 joined", err)
+	}
+}
+
+func TestRace(t *testing.T) {
+	boom := errors.New("boom")
+	slow := make(chan struct{})
+	_, err := join.Race(
+		after(time.Minute, 1, nil, slow),
+		after(10*time.Millisecond, 2, boom, nil),
+	).Await()
+
+	if !errors.Is(err, boom) {
+		t.Errorf("err = %v, want the first result even if it failed", err)
+	}
+	waitClosed(t, slow, "slow future")
+}
+
+func TestAllSettled(t *testing.T) {
+	boom := errors.New("boom")
+	results, err := join.AllSettled(
+		after(20*time.Millisecond, 1, nil, nil),
+		after(5*time.Millisecond, 2, boom, nil),
+	).Await()
+
+	if err != nil || len(results) != 2 {
+		t.Fatalf("AllSettled() = %v, %v", results, err)
+	}
+	if results[0].Value != 1 || results[0].Err != nil || !errors.Is(results[1].Err, boom) {
+		t.Errorf("results = %+v, want [{1 <nil>} {0 boom}]", results)
+	}
+}
+
+func TestCombinatorCancel(t *testing.T) {
+	slow := make(chan struct{})
+	f := join.AllSettled(after(time.Minute, 1, nil, slow))
+	f.Cancel()
+
+	results, _ := f.Await()
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", results[0].Err)
+	}
+	waitClosed(t, slow, "input future")
+}
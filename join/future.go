@@ -0,0 +1,124 @@
+// Package join 把 other/gojingjin/33/2_join_model.go 里的 Spawn/spawnGroup 整理成带类型的泛型版本：
+// 不再用 ...interface{} 传参数和类型断言取结果，goroutine 里的 panic 也会变成错误返回。
+package join
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrAwaitTimeout AwaitTimeout 等待超时。此时 goroutine 仍在运行，需要的话调用 Cancel。
+var ErrAwaitTimeout = errors.New("join: await timeout")
+
+// PanicError goroutine 里发生了 panic。
+type PanicError struct {
+	Value any
+	Stack []byte // 发生 panic 时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap panic(err) 时可以用 errors.Is/As 找到原来的错误。
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Future 一个正在运行的 goroutine 的结果。
+type Future[T any] struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	value  T
+	err    error
+}
+
+// Spawn 在新的 goroutine 里运行 f。f 拿到的 ctx 在父 ctx 结束或者调用 Cancel 时被取消；
+// f 返回之后这个 ctx 也会被释放。
+func Spawn[T any](ctx context.Context, f func(ctx context.Context) (T, error)) *Future[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	fu := &Future[T]{done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(fu.done)
+		defer cancel()
+		fu.value, fu.err = call(ctx, f)
+	}()
+	return fu
+}
+
+// call 调用 f，把 panic 转换成 *PanicError。
+func call[T any](ctx context.Context, f func(ctx context.Context) (T, error)) (v T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return f(ctx)
+}
+
+// Done 在 goroutine 结束时关闭。
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel 取消传给 goroutine 的 ctx。它不等待 goroutine 退出，需要等的话再调用 Await。
+func (f *Future[T]) Cancel() {
+	f.cancel()
+}
+
+// Await 等待 goroutine 结束，返回它的结果。可以重复调用，也可以在多个 goroutine 里同时调用。
+func (f *Future[T]) Await() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// AwaitTimeout 最多等待 d，超时返回 ErrAwaitTimeout，goroutine 不会被取消。
+func (f *Future[T]) AwaitTimeout(d time.Duration) (T, error) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-t.C:
+		var zero T
+		return zero, ErrAwaitTimeout
+	}
+}
+
+// Result 一个 goroutine 的结果。
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// SpawnGroup 每个函数一个 goroutine。返回的 Future 在所有 goroutine 都结束后完成，
+// 结果按 fs 的顺序排列；错误是所有失败的 goroutine 的错误合并在一起，没有失败时为nil。
+// 取消返回的 Future 会取消所有 goroutine。
+func SpawnGroup[T any](ctx context.Context, fs ...func(ctx context.Context) (T, error)) *Future[[]Result[T]] {
+	return Spawn(ctx, func(ctx context.Context) ([]Result[T], error) {
+		results := make([]Result[T], len(fs))
+		var wg sync.WaitGroup
+		for i, f := range fs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i].Value, results[i].Err = call(ctx, f)
+			}()
+		}
+		wg.Wait()
+
+		var errs []error
+		for _, r := range results {
+			if r.Err != nil {
+				errs = append(errs, r.Err)
+			}
+		}
+		return results, errors.Join(errs...)
+	})
+}
@@ -0,0 +1,115 @@
+package join_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"CInG/join"
+)
+
+func TestSpawnAwait(t *testing.T) {
+	f := join.Spawn(context.Background(), func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+
+	v, err := f.Await()
+	if v != 42 || err != nil {
+		t.Fatalf("Await() = %d, %v, want 42, nil", v, err)
+	}
+	select {
+	case <-f.Done():
+	default:
+		t.Error("Done not closed after Await returned")
+	}
+	if v, _ := f.Await(); v != 42 {
+		t.Errorf("second Await() = %d, want 42", v)
+	}
+}
+
+func TestSpawnCancel(t *testing.T) {
+	f := join.Spawn(context.Background(), func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	if _, err := f.AwaitTimeout(20 * time.Millisecond); !errors.Is(err, join.ErrAwaitTimeout) {
+		t.Fatalf("AwaitTimeout err = %v, want ErrAwaitTimeout", err)
+	}
+
+	f.Cancel()
+	if _, err := f.Await(); !errors.Is(err, context.Canceled) {
+		t.Errorf("err after Cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestSpawnParentContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := join.Spawn(ctx, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	cancel()
+	if _, err := f.Await(); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestSpawnRecoversPanic(t *testing.T) {
+	boom := errors.New("boom")
+	f := join.Spawn(context.Background(), func(ctx context.Context) (int, error) {
+		panic(boom)
+	})
+
+	_, err := f.Await()
+	var pe *join.PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("err = %v, want *PanicError", err)
+	}
+	if !errors.Is(err, boom) {
+		t.Errorf("err = %v, want it to wrap the panic value", err)
+	}
+	if !strings.Contains(string(pe.Stack), "TestSpawnRecoversPanic") {
+		t.Errorf("stack does not show where the panic happened:\n%s", pe.Stack)
+	}
+}
+
+func TestSpawnGroupOrder(t *testing.T) {
+	boom := errors.New("boom")
+	task := func(v int, d time.Duration, err error) func(context.Context) (int, error) {
+		return func(ctx context.Context) (int, error) {
+			time.Sleep(d)
+			return v, err
+		}
+	}
+
+	// 先结束的排在后面，结果仍然按传入的顺序。
+	f := join.SpawnGroup(context.Background(),
+		task(1, 30*time.Millisecond, nil),
+		task(2, 20*time.Millisecond, boom),
+		task(3, 10*time.Millisecond, nil),
+		func(ctx context.Context) (int, error) { panic("oops") },
+	)
+	results, err := f.Await()
+
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for i, want := range []int{1, 2, 3} {
+		if results[i].Value != want {
+			t.Errorf("results[%d] = %d, want %d", i, results[i].Value, want)
+		}
+	}
+	if !errors.Is(results[1].Err, boom) {
+		t.Errorf("results[1].Err = %v, want boom", results[1].Err)
+	}
+	var pe *join.PanicError
+	if !errors.As(results[3].Err, &pe) || pe.Value != "oops" {
+		t.Errorf("results[3].Err = %v, want a recovered panic", results[3].Err)
+	}
+	if !errors.Is(err, boom) || !errors.As(err, &pe) {
+		t.Errorf("group err = %v, want both failures joined", err)
+	}
+}
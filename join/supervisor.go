@@ -0,0 +1,153 @@
+package join
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrTooManyRestarts 重启太频繁，Supervisor 放弃并把错误交给上一层处理。
+var ErrTooManyRestarts = errors.New("join: too many restarts")
+
+// Strategy 一个 worker 失败时重启哪些 worker。
+type Strategy int
+
+const (
+	OneForOne Strategy = iota // 只重启失败的 worker
+	OneForAll                 // 停掉其他还在运行的 worker，然后一起重启
+)
+
+// SupervisorPolicy 重启策略。Window 内的重启次数超过 MaxRestarts 时，
+// Supervisor 停掉所有 worker，Run 返回 ErrTooManyRestarts。
+type SupervisorPolicy struct {
+	Strategy    Strategy
+	MaxRestarts int           // 0表示不重启，第一次失败就放弃
+	Window      time.Duration // 0表示5秒
+
+	Logger *log.Logger // 记录失败和重启，为nil时使用 log 包的默认 Logger
+}
+
+// Supervisor 运行一组 worker。worker 返回错误或者 panic（恢复成 *PanicError，带调用栈）都算失败，
+// 按策略重启；返回nil算正常结束，不再重启。
+type Supervisor struct {
+	policy  SupervisorPolicy
+	workers []supervised
+}
+
+type supervised struct {
+	name string
+	f    func(ctx context.Context) error
+}
+
+func NewSupervisor(policy SupervisorPolicy) *Supervisor {
+	if policy.Window <= 0 {
+		policy.Window = 5 * time.Second
+	}
+	return &Supervisor{policy: policy}
+}
+
+// Add 添加一个 worker，只能在 Run 之前调用。
+func (s *Supervisor) Add(name string, f func(ctx context.Context) error) {
+	s.workers = append(s.workers, supervised{name: name, f: f})
+}
+
+type exit struct {
+	i   int
+	err error
+}
+
+// Run 启动所有 worker，直到它们都正常结束、ctx 结束或者重启太频繁。
+// ctx 结束时取消所有 worker，等它们退出后返回nil；重启太频繁时返回的错误同时包装了
+// ErrTooManyRestarts 和最后一次失败的错误。
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	exits := make(chan exit)
+	cancels := make([]context.CancelFunc, len(s.workers))
+	running := make([]bool, len(s.workers))
+	n := 0 // 正在运行的 worker 数
+
+	start := func(i int) {
+		wctx, wcancel := context.WithCancel(ctx)
+		cancels[i] = wcancel
+		running[i] = true
+		n++
+		go func() {
+			_, err := call(wctx, func(ctx context.Context) (struct{}, error) {
+				return struct{}{}, s.workers[i].f(ctx)
+			})
+			exits <- exit{i: i, err: err}
+		}()
+	}
+
+	// OneForAll：被停掉等着一起重启的 worker，以及还有几个没退出。
+	restart := make(map[int]bool)
+	stopping := 0
+
+	var restarts []time.Time
+	var failure error
+
+	for i := range s.workers {
+		start(i)
+	}
+	for n > 0 {
+		e := <-exits
+		n--
+		running[e.i] = false
+		cancels[e.i]()
+
+		if ctx.Err() != nil {
+			continue // 正在退出，只等剩下的 worker
+		}
+		if restart[e.i] {
+			stopping-- // 被 OneForAll 停掉的，错误不算失败
+		} else if e.err != nil {
+			name := s.workers[e.i].name
+			s.logf("join: worker %s failed: %v", name, e.err)
+
+			now := time.Now()
+			for len(restarts) > 0 && now.Sub(restarts[0]) > s.policy.Window {
+				restarts = restarts[1:]
+			}
+			if len(restarts) >= s.policy.MaxRestarts {
+				failure = fmt.Errorf("%w: %s: %w", ErrTooManyRestarts, name, e.err)
+				cancel()
+				continue
+			}
+			restarts = append(restarts, now)
+
+			restart[e.i] = true
+			if s.policy.Strategy == OneForAll {
+				for j := range s.workers {
+					if running[j] && !restart[j] {
+						restart[j] = true
+						stopping++
+						cancels[j]()
+					}
+				}
+			}
+		}
+
+		if stopping == 0 && len(restart) > 0 {
+			for i := range s.workers {
+				if restart[i] {
+					s.logf("join: restarting worker %s", s.workers[i].name)
+					start(i)
+				}
+			}
+			clear(restart)
+		}
+	}
+	return failure
+}
+
+func (s *Supervisor) logf(format string, args ...any) {
+	if s.policy.Logger != nil {
+		s.policy.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,115 @@
+package join_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"CInG/join"
+)
+
+var quietLogger = log.New(io.Discard, "", 0)
+
+// flaky 前 fails 次启动时 panic，之后一直运行到 ctx 结束。
+func flaky(starts *atomic.Int32, fails int32) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if starts.Add(1) <= fails {
+			panic("bad order")
+		}
+		<-ctx.Done()
+		return nil
+	}
+}
+
+// steady 一直运行到 ctx 结束。
+func steady(starts *atomic.Int32) func(context.Context) error {
+	return func(ctx context.Context) error {
+		starts.Add(1)
+		<-ctx.Done()
+		return nil
+	}
+}
+
+func runFor(t *testing.T, s *join.Supervisor, d time.Duration) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return s.Run(ctx)
+}
+
+func TestSupervisorOneForOne(t *testing.T) {
+	var a, b atomic.Int32
+	s := join.NewSupervisor(join.SupervisorPolicy{Strategy: join.OneForOne, MaxRestarts: 5, Logger: quietLogger})
+	s.Add("a", flaky(&a, 2))
+	s.Add("b", steady(&b))
+
+	if err := runFor(t, s, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if a.Load() != 3 {
+		t.Errorf("a started %d times, want 3 (two panics, then restarted)", a.Load())
+	}
+	if b.Load() != 1 {
+		t.Errorf("b started %d times, want 1: one-for-one must not restart healthy workers", b.Load())
+	}
+}
+
+func TestSupervisorOneForAll(t *testing.T) {
+	var a, b atomic.Int32
+	s := join.NewSupervisor(join.SupervisorPolicy{Strategy: join.OneForAll, MaxRestarts: 5, Logger: quietLogger})
+	s.Add("a", func(ctx context.Context) error {
+		if a.Add(1) == 1 {
+			time.Sleep(10 * time.Millisecond) // 等 b 先启动
+			return errors.New("lost connection")
+		}
+		<-ctx.Done()
+		return nil
+	})
+	s.Add("b", steady(&b))
+
+	if err := runFor(t, s, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if a.Load() != 2 || b.Load() != 2 {
+		t.Errorf("starts: a=%d b=%d, want both restarted once", a.Load(), b.Load())
+	}
+}
+
+func TestSupervisorEscalates(t *testing.T) {
+	var a, b atomic.Int32
+	s := join.NewSupervisor(join.SupervisorPolicy{MaxRestarts: 2, Window: time.Minute, Logger: quietLogger})
+	s.Add("a", flaky(&a, 1000))
+	s.Add("b", steady(&b))
+
+	err := runFor(t, s, time.Second)
+	if !errors.Is(err, join.ErrTooManyRestarts) {
+		t.Fatalf("err = %v, want ErrTooManyRestarts", err)
+	}
+	var pe *join.PanicError
+	if !errors.As(err, &pe) || pe.Value != "bad order" || len(pe.Stack) == 0 {
+		t.Errorf("err = %v, want the last panic with its stack", err)
+	}
+	if a.Load() != 3 {
+		t.Errorf("a started %d times, want 3 (first run and 2 restarts)", a.Load())
+	}
+}
+
+func TestSupervisorWorkersFinish(t *testing.T) {
+	var runs atomic.Int32
+	s := join.NewSupervisor(join.SupervisorPolicy{MaxRestarts: 5, Logger: quietLogger})
+	s.Add("once", func(context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if runs.Load() != 1 {
+		t.Errorf("worker ran %d times, want 1: a nil return is not a failure", runs.Load())
+	}
+}
@@ -0,0 +1,167 @@
+// Package leaktest 在测试结束时检查goroutine泄漏。
+//
+// 用法：测试的第一行调用 leaktest.Check(t)；或者在 TestMain 里用 leaktest.Main(m) 检查整个包。
+// 测试开始之后新启动、过了 settle 时间还没退出的goroutine算作泄漏，测试失败并打印它们的调用栈。
+package leaktest
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultSettle 默认等多久让goroutine自己退出。
+const DefaultSettle = time.Second
+
+// defaultIgnore 运行时和测试框架自己的后台goroutine，它们不属于被测代码。
+var defaultIgnore = []string{
+	"testing.tRunner(",      // 其他测试（包括 t.Parallel 的测试）
+	"testing.(*M).",         // 测试主goroutine
+	"os/signal.signal_recv", // signal.Notify 之后一直存在
+	"os/signal.loop",
+	"runtime.ensureSigM",
+}
+
+type config struct {
+	settle time.Duration
+	ignore []string
+}
+
+type Option func(*config)
+
+// Settle 设置等待goroutine退出的时间，默认 DefaultSettle。
+func Settle(d time.Duration) Option {
+	return func(c *config) {
+		c.settle = d
+	}
+}
+
+// Ignore 调用栈里包含任意一个 substr 的goroutine不算泄漏，比如 "net/http.(*persistConn)"。
+func Ignore(substr ...string) Option {
+	return func(c *config) {
+		c.ignore = append(c.ignore, substr...)
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{settle: DefaultSettle, ignore: append([]string(nil), defaultIgnore...)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Check 记下当前所有的goroutine，测试结束时（t.Cleanup）检查新启动的goroutine是否都已经退出。
+// 应该在测试一开始就调用：先注册的 Cleanup 后执行，这样测试自己的清理函数会在检查之前跑完。
+func Check(t testing.TB, opts ...Option) {
+	t.Helper()
+	c := newConfig(opts)
+	before := snapshot()
+	t.Cleanup(func() {
+		if leaked := c.wait(before); len(leaked) > 0 {
+			t.Errorf("leaktest: %d goroutine(s) still running after %v:\n\n%s", len(leaked), c.settle, format(leaked))
+		}
+	})
+}
+
+// Main 运行整个包的测试，然后检查测试期间启动的goroutine是否都已经退出。
+// 在 TestMain 里调用：func TestMain(m *testing.M) { leaktest.Main(m) }
+func Main(m *testing.M, opts ...Option) {
+	c := newConfig(opts)
+	before := snapshot()
+	code := m.Run()
+	if code == 0 {
+		if leaked := c.wait(before); len(leaked) > 0 {
+			fmt.Fprintf(os.Stderr, "leaktest: %d goroutine(s) still running after all tests and %v:\n\n%s\n",
+				len(leaked), c.settle, format(leaked))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// wait 每隔一小段时间检查一次，直到没有泄漏或者超过 settle 时间。
+func (c *config) wait(before map[string]bool) []goroutine {
+	deadline := time.Now().Add(c.settle)
+	backoff := time.Millisecond
+	for {
+		leaked := c.leaked(before)
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, 100*time.Millisecond)
+	}
+}
+
+func (c *config) leaked(before map[string]bool) []goroutine {
+	var leaked []goroutine
+	for _, g := range goroutines() {
+		if before[g.id] || c.ignored(g) {
+			continue
+		}
+		leaked = append(leaked, g)
+	}
+	return leaked
+}
+
+func (c *config) ignored(g goroutine) bool {
+	for _, s := range c.ignore {
+		if strings.Contains(g.stack, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type goroutine struct {
+	id    string
+	stack string // 包括 "goroutine N [state]:" 这一行
+}
+
+func snapshot() map[string]bool {
+	ids := make(map[string]bool)
+	for _, g := range goroutines() {
+		ids[g.id] = true
+	}
+	return ids
+}
+
+// goroutines 解析 runtime.Stack 的输出，每个goroutine之间用空行分隔。
+func goroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	var gs []goroutine
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		// goroutine 18 [chan receive]:
+		header, _, _ := strings.Cut(stack, "\n")
+		id, ok := strings.CutPrefix(header, "goroutine ")
+		if !ok {
+			continue
+		}
+		id, _, _ = strings.Cut(id, " ")
+		gs = append(gs, goroutine{id: id, stack: strings.TrimSpace(stack)})
+	}
+	return gs
+}
+
+func format(gs []goroutine) string {
+	sort.Slice(gs, func(i, j int) bool { return gs[i].stack < gs[j].stack })
+	stacks := make([]string, len(gs))
+	for i, g := range gs {
+		stacks[i] = g.stack
+	}
+	return strings.Join(stacks, "\n\n")
+}
@@ -0,0 +1,79 @@
+package leaktest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"CInG/leaktest"
+)
+
+// fakeT 记录 Errorf，Cleanup 由测试手动执行。
+type fakeT struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func blockForever(stop chan struct{}) {
+	<-stop
+}
+
+func TestCheckReportsLeak(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	ft := &fakeT{TB: t}
+	leaktest.Check(ft, leaktest.Settle(50*time.Millisecond))
+	go blockForever(stop)
+	ft.finish()
+
+	if len(ft.errors) != 1 {
+		t.Fatalf("errors = %v, want one leak report", ft.errors)
+	}
+	if !strings.Contains(ft.errors[0], "leaktest_test.blockForever") {
+		t.Errorf("report does not show the leaking stack:\n%s", ft.errors[0])
+	}
+}
+
+func TestCheckWaitsForSettle(t *testing.T) {
+	ft := &fakeT{TB: t}
+	leaktest.Check(ft, leaktest.Settle(time.Second))
+	go time.Sleep(50 * time.Millisecond) // 在 settle 时间内自己退出，不算泄漏
+	ft.finish()
+
+	if len(ft.errors) != 0 {
+		t.Errorf("errors = %v, want none", ft.errors)
+	}
+}
+
+func TestCheckIgnore(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	ft := &fakeT{TB: t}
+	leaktest.Check(ft, leaktest.Settle(50*time.Millisecond), leaktest.Ignore("leaktest_test.blockForever"))
+	go blockForever(stop)
+	ft.finish()
+
+	if len(ft.errors) != 0 {
+		t.Errorf("errors = %v, want the ignored goroutine not reported", ft.errors)
+	}
+}
@@ -0,0 +1,210 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// App 按注册顺序启动组件，收到 SIGINT/SIGTERM 后按相反的顺序退出它们。
+// 退出期间再收到一次 SIGINT/SIGTERM 就强制退出；SIGHUP 触发 reload 钩子。
+type App struct {
+	shutdownTimeout time.Duration
+	reload          func(ctx context.Context) error
+	forceExit       func()
+	logger          *log.Logger
+
+	mu         sync.Mutex
+	components []appComponent
+	started    int // 已经启动成功的组件数，Shutdown 只退出这些
+}
+
+type appComponent struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+type AppOption func(*App)
+
+// WithShutdownTimeout 所有组件退出的总时间，默认30秒。
+func WithShutdownTimeout(d time.Duration) AppOption {
+	return func(a *App) {
+		a.shutdownTimeout = d
+	}
+}
+
+// WithReload 收到 SIGHUP 时调用 f。f 返回的错误只记录日志，不会让程序退出。
+func WithReload(f func(ctx context.Context) error) AppOption {
+	return func(a *App) {
+		a.reload = f
+	}
+}
+
+// WithForceExit 退出期间收到第二个信号时调用 f，默认是 os.Exit(1)。
+func WithForceExit(f func()) AppOption {
+	return func(a *App) {
+		a.forceExit = f
+	}
+}
+
+// WithLogger 记录信号和启动退出事件，为nil时使用 log 包的默认 Logger。
+func WithLogger(l *log.Logger) AppOption {
+	return func(a *App) {
+		a.logger = l
+	}
+}
+
+func NewApp(opts ...AppOption) *App {
+	a := &App{
+		shutdownTimeout: 30 * time.Second,
+		forceExit:       func() { os.Exit(1) },
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Register 注册一个组件。start 和 stop 都可以为nil。
+// start 拿到的 ctx 在程序开始退出时会被取消，组件需要一直运行下去的goroutine不要直接挂在它上面，
+// 应该在 stop 里结束它们。
+func (a *App) Register(name string, start, stop func(ctx context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.components = append(a.components, appComponent{name: name, start: start, stop: stop})
+}
+
+// Start 按注册顺序启动组件。某个组件启动失败时，后面的组件不再启动，
+// 已经启动的组件按相反的顺序退出（最多 WithShutdownTimeout 那么久），
+// 返回的错误是启动错误和回滚时的退出错误合并在一起的结果。
+func (a *App) Start(ctx context.Context) error {
+	a.mu.Lock()
+	components := a.components[a.started:]
+	a.mu.Unlock()
+
+	for _, c := range components {
+		if c.start != nil {
+			if err := c.start(ctx); err != nil {
+				err = fmt.Errorf("start %s: %w", c.name, err)
+				return errors.Join(err, a.rollback(err))
+			}
+		}
+		a.mu.Lock()
+		a.started++
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+func (a *App) rollback(cause error) error {
+	a.logf("lifecycle: %v, rolling back", cause)
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+	return a.Shutdown(ctx).Err()
+}
+
+// Shutdown 按注册的相反顺序退出已经启动的组件，整体不超过 ctx 的截止时间。
+func (a *App) Shutdown(ctx context.Context) *ShutdownReport {
+	a.mu.Lock()
+	components := a.components[:a.started]
+	a.started = 0
+	a.mu.Unlock()
+
+	shutdowners := make([]ContextShutdowner, 0, len(components))
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		stop := c.stop
+		if stop == nil {
+			stop = func(context.Context) error { return nil }
+		}
+		shutdowners = append(shutdowners, NamedContext(c.name, ContextShutdownFunc(stop)))
+	}
+	return SequentialShutdownContext(ctx, shutdowners...)
+}
+
+// Run 启动所有组件，然后一直等到收到 SIGINT/SIGTERM 或者 ctx 结束，再退出所有组件。
+// 启动失败时已经启动的组件会被回滚，返回的错误见 Start；否则返回退出错误，见 ShutdownReport.Err。
+func (a *App) Run(ctx context.Context) error {
+	// 先订阅信号再启动：启动期间收到的信号会在启动完成后处理，不会直接杀掉进程。
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	// start 拿到的是 runCtx，开始退出时先取消它，再调用各个组件的 stop。
+	runCtx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+
+	if err := a.Start(runCtx); err != nil {
+		return err
+	}
+	a.logf("lifecycle: started")
+
+wait:
+	for {
+		select {
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				a.doReload(runCtx)
+				continue
+			}
+			a.logf("lifecycle: received %v, shutting down", sig)
+			break wait
+		case <-ctx.Done():
+			a.logf("lifecycle: %v, shutting down", context.Cause(ctx))
+			break wait
+		}
+	}
+
+	sctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				if sig == syscall.SIGHUP {
+					continue // 退出期间不再 reload
+				}
+				a.logf("lifecycle: received %v again, forcing exit", sig)
+				cancel()
+				a.forceExit()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	stopRun()
+	r := a.Shutdown(sctx)
+	a.logf("lifecycle: stopped in %v", r.Elapsed.Round(time.Millisecond))
+	return r.Err()
+}
+
+func (a *App) doReload(ctx context.Context) {
+	if a.reload == nil {
+		a.logf("lifecycle: received SIGHUP, no reload hook")
+		return
+	}
+	a.logf("lifecycle: received SIGHUP, reloading")
+	if err := a.reload(ctx); err != nil {
+		a.logf("lifecycle: reload: %v", err)
+	}
+}
+
+func (a *App) logf(format string, args ...any) {
+	if a.logger != nil {
+		a.logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,81 @@
+//go:build unix
+
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"CInG/lifecycle"
+)
+
+func TestAppReloadOnSIGHUP(t *testing.T) {
+	reloaded := make(chan struct{}, 1)
+	started := make(chan struct{})
+
+	a := lifecycle.NewApp(quiet, lifecycle.WithReload(func(context.Context) error {
+		reloaded <- struct{}{}
+		return nil
+	}))
+	a.Register("ready", func(context.Context) error {
+		close(started)
+		return nil
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- a.Run(ctx) }()
+
+	<-started
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("reload hook not called on SIGHUP")
+	}
+
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppSecondSignalForcesExit(t *testing.T) {
+	started := make(chan struct{})
+	stopping := make(chan struct{})
+	forced := make(chan struct{})
+
+	a := lifecycle.NewApp(quiet,
+		lifecycle.WithShutdownTimeout(time.Minute),
+		lifecycle.WithForceExit(func() { close(forced) }))
+	a.Register("stuck", func(context.Context) error {
+		close(started)
+		return nil
+	}, func(ctx context.Context) error {
+		close(stopping)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	errc := make(chan error, 1)
+	go func() { errc <- a.Run(context.Background()) }()
+
+	<-started
+	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	<-stopping
+	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+
+	select {
+	case <-forced:
+	case <-time.After(time.Second):
+		t.Fatal("second signal did not force exit")
+	}
+	if err := <-errc; !errors.Is(err, lifecycle.ErrTimeout) {
+		t.Errorf("err = %v, want the stuck component to time out", err)
+	}
+}
@@ -0,0 +1,132 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+
+	"CInG/lifecycle"
+)
+
+var quiet = lifecycle.WithLogger(log.New(io.Discard, "", 0))
+
+func TestAppStartStopOrder(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	hook := func(event string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	a := lifecycle.NewApp(quiet)
+	a.Register("db", hook("start db"), hook("stop db"))
+	a.Register("cache", nil, hook("stop cache"))
+	a.Register("http", hook("start http"), hook("stop http"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.Register("trigger", func(context.Context) error {
+		cancel() // 启动完成后立即退出
+		return nil
+	}, nil)
+
+	if err := a.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"start db", "start http", "stop http", "stop cache", "stop db"}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("events = %v, want %v", events, want)
+		}
+	}
+}
+
+func TestAppStartFailureRollsBack(t *testing.T) {
+	var mu sync.Mutex
+	var stopped []string
+	stop := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+			return err
+		}
+	}
+	boom := errors.New("boom")
+	closeErr := errors.New("close failed")
+	ok := func(context.Context) error { return nil }
+	lateStarted := false
+
+	a := lifecycle.NewApp(quiet)
+	a.Register("db", ok, stop("db", closeErr))
+	a.Register("cache", ok, stop("cache", nil))
+	a.Register("http", func(context.Context) error { return boom }, stop("http", nil))
+	a.Register("worker", func(context.Context) error {
+		lateStarted = true
+		return nil
+	}, stop("worker", nil))
+
+	err := a.Run(context.Background())
+	if !errors.Is(err, boom) {
+		t.Errorf("err = %v, want the start error", err)
+	}
+	if !errors.Is(err, closeErr) {
+		t.Errorf("err = %v, want the cleanup error joined in", err)
+	}
+	var ce *lifecycle.ComponentError
+	if !errors.As(err, &ce) || ce.Name != "db" {
+		t.Errorf("err = %v, want a ComponentError for db", err)
+	}
+	if lateStarted {
+		t.Error("component after a failed one was started")
+	}
+
+	// 只回滚启动成功的组件，顺序和启动相反。
+	if len(stopped) != 2 || stopped[0] != "cache" || stopped[1] != "db" {
+		t.Errorf("stopped = %v, want [cache db]", stopped)
+	}
+
+	// 回滚之后再退出不会重复退出组件。
+	if err := a.Shutdown(context.Background()).Err(); err != nil || len(stopped) != 2 {
+		t.Errorf("second shutdown: err=%v stopped=%v", err, stopped)
+	}
+}
+
+func TestAppCancelsStartContextOnShutdown(t *testing.T) {
+	var startCtx context.Context
+	var liveAtStop bool
+	a := lifecycle.NewApp(quiet)
+	a.Register("worker", func(ctx context.Context) error {
+		startCtx = ctx
+		return nil
+	}, func(context.Context) error {
+		liveAtStop = startCtx.Err() == nil
+		return nil
+	})
+	// 用信号触发退出，调用方传给 Run 的 ctx 一直没有取消。
+	a.Register("trigger", func(context.Context) error {
+		p, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			return err
+		}
+		return p.Signal(os.Interrupt)
+	}, nil)
+
+	if err := a.Run(context.Background()); err != nil {
+		t.Skipf("cannot send an interrupt to the test process: %v", err)
+	}
+	if liveAtStop {
+		t.Error("start ctx was still live when the stop hooks ran")
+	}
+}
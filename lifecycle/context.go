@@ -0,0 +1,128 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ContextShutdowner 用 ctx 代替 waitTimeout 的退出接口。
+// ctx 的截止时间就是退出的截止时间；截止时间到了 ctx 会被取消，组件应当尽快放弃剩下的清理工作并返回，
+// 这样超时之后不会留下还在运行的goroutine。
+type ContextShutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// ContextShutdownFunc 让普通函数也能当 ContextShutdowner 用。
+type ContextShutdownFunc func(ctx context.Context) error
+
+func (f ContextShutdownFunc) Shutdown(ctx context.Context) error {
+	return f(ctx)
+}
+
+// FromGraceful 把老的 GracefullyShutdowner 适配成 ContextShutdowner：waitTimeout 取 ctx 剩下的时间，
+// ctx 被取消时立即返回 ctx.Err()。老接口没法被取消，它自己的 Shutdown 还会在后台跑完。
+func FromGraceful(s GracefullyShutdowner) ContextShutdowner {
+	return gracefulAdapter{s: s}
+}
+
+type gracefulAdapter struct {
+	s GracefullyShutdowner
+}
+
+func (a gracefulAdapter) Shutdown(ctx context.Context) error {
+	waitTimeout := time.Duration(1<<63 - 1)
+	if deadline, ok := ctx.Deadline(); ok {
+		waitTimeout = time.Until(deadline)
+	}
+
+	c := make(chan error, 1) // 带缓冲：ctx 先结束时，后台的 Shutdown 返回后不会卡在发送上
+	go func() {
+		c <- a.s.Shutdown(waitTimeout)
+	}()
+
+	select {
+	case err := <-c:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NamedContext 给 ContextShutdowner 起个名字，报告里用它来区分组件。
+func NamedContext(name string, s ContextShutdowner) ContextShutdowner {
+	return namedContextShutdowner{name: name, ContextShutdowner: s}
+}
+
+type namedContextShutdowner struct {
+	name string
+	ContextShutdowner
+}
+
+func (n namedContextShutdowner) Name() string {
+	return n.name
+}
+
+// ConcurrentShutdownContext 所有组件同时退出，直到全部返回或者 ctx 结束。
+// ctx 结束时还没返回的组件记为超时，它们拿到的 ctx 也随之被取消。
+func ConcurrentShutdownContext(ctx context.Context, shutdowners ...ContextShutdowner) *ShutdownReport {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t := newTracker(ctx, contextNames(shutdowners))
+	c := make(chan struct{})
+
+	go func() {
+		var wg sync.WaitGroup
+		for i, s := range shutdowners {
+			wg.Add(1)
+			go func(i int, s ContextShutdowner) {
+				defer wg.Done()
+				t.start(i)
+				t.finish(i, s.Shutdown(ctx))
+			}(i, s)
+		}
+
+		wg.Wait()
+		close(c) // 用 close 而不是发送：超时返回之后没有人接收，这个goroutine也不会卡住
+	}()
+
+	select {
+	case <-c:
+	case <-ctx.Done():
+	}
+	return t.seal()
+}
+
+// SequentialShutdownContext 按顺序依次退出，整体不超过 ctx 的截止时间。
+// 某个组件超时之后，它的 ctx 被取消，后面的组件不再退出，在报告里记为 Skipped。
+func SequentialShutdownContext(ctx context.Context, shutdowners ...ContextShutdowner) *ShutdownReport {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t := newTracker(ctx, contextNames(shutdowners))
+	for i, s := range shutdowners {
+		c := make(chan struct{})
+		t.start(i)
+		go func() {
+			t.finish(i, s.Shutdown(ctx))
+			close(c)
+		}()
+
+		select {
+		case <-c:
+			// 继续执行，组件返回了错误也继续退出后面的组件
+		case <-ctx.Done():
+			return t.seal()
+		}
+	}
+	return t.seal()
+}
+
+func contextNames(shutdowners []ContextShutdowner) []string {
+	ns := make([]string, len(shutdowners))
+	for i, s := range shutdowners {
+		ns[i] = nameOf(i, s)
+	}
+	return ns
+}
@@ -0,0 +1,147 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"CInG/lifecycle"
+)
+
+// cooperative 一直等到 ctx 被取消才返回，返回前关闭 exited。
+func cooperative(exited chan struct{}) lifecycle.ContextShutdowner {
+	return lifecycle.ContextShutdownFunc(func(ctx context.Context) error {
+		defer close(exited)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+}
+
+func TestConcurrentShutdownContextCancelsAtDeadline(t *testing.T) {
+	exited := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	r := lifecycle.ConcurrentShutdownContext(ctx,
+		lifecycle.NamedContext("fast", lifecycle.ContextShutdownFunc(func(ctx context.Context) error { return nil })),
+		lifecycle.NamedContext("stuck", cooperative(exited)))
+
+	if got := r.TimedOut(); len(got) != 1 || got[0] != "stuck" {
+		t.Errorf("timed out = %v, want [stuck]", got)
+	}
+	if !errors.Is(r.Err(), lifecycle.ErrTimeout) {
+		t.Errorf("err = %v, want ErrTimeout", r.Err())
+	}
+
+	// 超时之后组件拿到的 ctx 已经被取消，它的goroutine应该很快退出。
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("component still running after deadline")
+	}
+}
+
+func TestFromGracefulReturnsAtDeadline(t *testing.T) {
+	got := make(chan time.Duration, 1)
+	legacy := lifecycle.ShutdownFunc(func(waitTimeout time.Duration) error {
+		got <- waitTimeout
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	err := lifecycle.FromGraceful(legacy).Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+		t.Errorf("returned after %v, want about 50ms", elapsed)
+	}
+	if d := <-got; d <= 0 || d > 50*time.Millisecond {
+		t.Errorf("waitTimeout = %v, want the remaining time of ctx", d)
+	}
+}
+
+func TestSequentialShutdownContextSkipsAfterTimeout(t *testing.T) {
+	exited := make(chan struct{})
+	ran := false
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	r := lifecycle.SequentialShutdownContext(ctx,
+		lifecycle.NamedContext("stuck", cooperative(exited)),
+		lifecycle.NamedContext("after", lifecycle.ContextShutdownFunc(func(ctx context.Context) error {
+			ran = true
+			return nil
+		})))
+
+	<-exited
+	if ran {
+		t.Error("component after a timeout was shut down")
+	}
+	if !r.Components[0].TimedOut || !r.Components[1].Skipped {
+		t.Errorf("report = %+v, want stuck timed out and after skipped", r.Components)
+	}
+}
+
+func TestShutdownGraphContext(t *testing.T) {
+	exited := make(chan struct{})
+	g := lifecycle.NewShutdownGraph()
+	must(t, g.Register("db", lifecycle.ShutdownFunc(func(time.Duration) error { return nil })))
+	must(t, g.RegisterContext("worker", cooperative(exited), "db"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	r, err := g.ShutdownContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// worker 超时，db 要等 worker，所以没有开始退出。
+	if !r.Components[0].Skipped || !r.Components[1].TimedOut {
+		t.Errorf("report = %+v, want db skipped and worker timed out", r.Components)
+	}
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("worker still running after deadline")
+	}
+}
+
+func TestShutdownGraphContextExpired(t *testing.T) {
+	var calls atomic.Int32
+	component := lifecycle.ContextShutdownFunc(func(context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// select 在几个就绪的分支里随机选，多跑几次。
+	for range 100 {
+		g := lifecycle.NewShutdownGraph()
+		must(t, g.RegisterContext("cache", component))
+		must(t, g.RegisterContext("db", component))
+		must(t, g.RegisterContext("api", component, "db"))
+
+		r, err := g.ShutdownContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range r.Components {
+			if !c.Skipped || c.TimedOut {
+				t.Fatalf("%s: skipped=%v timed out=%v, want skipped with an expired ctx", c.Name, c.Skipped, c.TimedOut)
+			}
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond) // ShutdownContext 返回之后也不能再有组件开始退出
+	if n := calls.Load(); n != 0 {
+		t.Errorf("Shutdown called %d times with an expired ctx, want 0", n)
+	}
+}
@@ -0,0 +1,11 @@
+package lifecycle_test
+
+import (
+	"testing"
+
+	"CInG/leaktest"
+)
+
+func TestMain(m *testing.M) {
+	leaktest.Main(m)
+}
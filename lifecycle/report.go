@@ -0,0 +1,167 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTimeout 组件没能在截止时间之前退出。可以用 errors.Is 在退出错误里找它，
+// 用 errors.As 取出 *ComponentError 看是哪个组件。
+var ErrTimeout = errors.New("shutdown timeout")
+
+// ErrSkipped 组件到截止时间都没有开始退出。
+var ErrSkipped = errors.New("shutdown skipped")
+
+// ComponentError 某个组件退出失败。
+type ComponentError struct {
+	Name string
+	Err  error
+}
+
+func (e *ComponentError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *ComponentError) Unwrap() error {
+	return e.Err
+}
+
+// ComponentReport 单个组件的退出情况。
+type ComponentReport struct {
+	Name     string
+	Duration time.Duration // 从开始退出到 Shutdown 返回（超时的组件到截止时间为止）
+	Err      error         // Shutdown 返回的错误
+	TimedOut bool          // 截止时间到了还没返回
+	Skipped  bool          // 截止时间到了还没开始退出（它要等的组件超时了）
+}
+
+// ShutdownReport 一次退出的完整报告，Components 按传入（注册）顺序排列。
+type ShutdownReport struct {
+	Components []ComponentReport
+	Elapsed    time.Duration
+}
+
+// Err 把所有失败、超时和被跳过的组件合并成一个错误，没有失败时返回nil。
+func (r *ShutdownReport) Err() error {
+	var errs []error
+	for _, c := range r.Components {
+		switch {
+		case c.TimedOut:
+			errs = append(errs, &ComponentError{Name: c.Name, Err: ErrTimeout})
+		case c.Skipped:
+			errs = append(errs, &ComponentError{Name: c.Name, Err: ErrSkipped})
+		case c.Err != nil:
+			errs = append(errs, &ComponentError{Name: c.Name, Err: c.Err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TimedOut 返回超时组件的名字。
+func (r *ShutdownReport) TimedOut() []string {
+	var names []string
+	for _, c := range r.Components {
+		if c.TimedOut {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// Named 给组件起个名字，报告里用它来区分组件。
+func Named(name string, s GracefullyShutdowner) GracefullyShutdowner {
+	return namedShutdowner{name: name, GracefullyShutdowner: s}
+}
+
+type namedShutdowner struct {
+	name string
+	GracefullyShutdowner
+}
+
+func (n namedShutdowner) Name() string {
+	return n.name
+}
+
+// nameOf 组件实现了 Name() string 就用它，否则用序号和类型。适配器包装的组件看里面那一层。
+func nameOf(i int, s any) string {
+	if a, ok := s.(gracefulAdapter); ok {
+		s = a.s
+	}
+	if n, ok := s.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("#%d(%T)", i, s)
+}
+
+// tracker 在组件goroutine和等待方之间收集报告。
+// ctx 结束之后才返回的组件算超时；调用 seal 之后报告不再改动。
+type tracker struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	begin   time.Time
+	report  ShutdownReport
+	started []time.Time
+	done    []bool
+	sealed  bool
+}
+
+func newTracker(ctx context.Context, names []string) *tracker {
+	t := &tracker{
+		ctx:     ctx,
+		begin:   time.Now(),
+		started: make([]time.Time, len(names)),
+		done:    make([]bool, len(names)),
+	}
+	t.report.Components = make([]ComponentReport, len(names))
+	for i, name := range names {
+		t.report.Components[i].Name = name
+	}
+	return t
+}
+
+func (t *tracker) start(i int) {
+	t.mu.Lock()
+	t.started[i] = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *tracker) finish(i int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sealed || t.ctx.Err() != nil {
+		return // 截止时间之后才返回，seal 会把它记为超时
+	}
+	t.done[i] = true
+	t.report.Components[i].Duration = time.Since(t.started[i])
+	t.report.Components[i].Err = err
+}
+
+// seal 结束收集：开始了但没结束的组件记为超时，没开始的记为跳过。
+func (t *tracker) seal() *ShutdownReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.sealed {
+		t.sealed = true
+		now := time.Now()
+		for i := range t.report.Components {
+			c := &t.report.Components[i]
+			switch {
+			case t.done[i]:
+			case t.started[i].IsZero():
+				c.Skipped = true
+			default:
+				c.TimedOut = true
+				c.Duration = now.Sub(t.started[i])
+			}
+		}
+		t.report.Elapsed = now.Sub(t.begin)
+	}
+
+	r := t.report
+	r.Components = append([]ComponentReport(nil), t.report.Components...)
+	return &r
+}
@@ -0,0 +1,93 @@
+package lifecycle_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"CInG/lifecycle"
+)
+
+func TestConcurrentShutdownReport(t *testing.T) {
+	r := newRecorder()
+	errFlush := errors.New("flush failed")
+
+	report := lifecycle.ConcurrentShutdownWithReport(50*time.Millisecond,
+		lifecycle.Named("http", r.component("http", 5*time.Millisecond, nil)),
+		lifecycle.Named("cache", r.component("cache", 5*time.Millisecond, errFlush)),
+		lifecycle.Named("queue", r.component("queue", time.Second, nil)),
+	)
+
+	if len(report.Components) != 3 {
+		t.Fatalf("got %d components, want 3", len(report.Components))
+	}
+	http, cache, queue := report.Components[0], report.Components[1], report.Components[2]
+	if http.Name != "http" || http.Err != nil || http.TimedOut || http.Duration < 5*time.Millisecond {
+		t.Errorf("http = %+v", http)
+	}
+	if cache.Name != "cache" || !errors.Is(cache.Err, errFlush) || cache.TimedOut {
+		t.Errorf("cache = %+v", cache)
+	}
+	if !queue.TimedOut || queue.Duration < 50*time.Millisecond {
+		t.Errorf("queue = %+v, want timed out", queue)
+	}
+
+	err := report.Err()
+	if !errors.Is(err, lifecycle.ErrTimeout) || !errors.Is(err, errFlush) {
+		t.Errorf("Err() = %v, want both the timeout and the flush error", err)
+	}
+	var ce *lifecycle.ComponentError
+	if !errors.As(err, &ce) || ce.Name != "cache" {
+		t.Errorf("first component error = %v, want cache", ce)
+	}
+	if names := report.TimedOut(); len(names) != 1 || names[0] != "queue" {
+		t.Errorf("TimedOut() = %v, want [queue]", names)
+	}
+}
+
+func TestSequentialShutdownReport(t *testing.T) {
+	r := newRecorder()
+	noop := lifecycle.ShutdownFunc(func(time.Duration) error { return nil })
+
+	report := lifecycle.SequentialShutdownWithReport(30*time.Millisecond,
+		noop,
+		lifecycle.Named("slow", r.component("slow", time.Second, nil)),
+		lifecycle.Named("db", r.component("db", 0, nil)),
+	)
+
+	first, slow, db := report.Components[0], report.Components[1], report.Components[2]
+	if first.Name != "#0(lifecycle.ShutdownFunc)" || first.TimedOut || first.Skipped {
+		t.Errorf("first = %+v", first)
+	}
+	if !slow.TimedOut {
+		t.Errorf("slow = %+v, want timed out", slow)
+	}
+	if !db.Skipped || db.TimedOut {
+		t.Errorf("db = %+v, want skipped", db)
+	}
+	if err := report.Err(); !errors.Is(err, lifecycle.ErrTimeout) || !errors.Is(err, lifecycle.ErrSkipped) {
+		t.Errorf("Err() = %v, want timeout and skipped", err)
+	}
+	if err := lifecycle.SequentialShutdown(time.Second, noop, noop); err != nil {
+		t.Errorf("SequentialShutdown = %v, want nil", err)
+	}
+}
+
+func TestShutdownGraphReport(t *testing.T) {
+	r := newRecorder()
+	g := lifecycle.NewShutdownGraph()
+	must(t, g.Register("db", r.component("db", 0, nil)))
+	must(t, g.Register("worker", r.component("worker", time.Second, nil), "db"))
+
+	report, err := g.ShutdownWithReport(20 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, worker := report.Components[0], report.Components[1]
+	if db.Name != "db" || !db.Skipped {
+		t.Errorf("db = %+v, want skipped while waiting for worker", db)
+	}
+	if worker.Name != "worker" || !worker.TimedOut {
+		t.Errorf("worker = %+v, want timed out", worker)
+	}
+}
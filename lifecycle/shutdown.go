@@ -0,0 +1,227 @@
+// Package lifecycle 把 other/gojingjin/33/4_exit_model_application.go 里的退出模型整理成可以直接使用的包。
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type GracefullyShutdowner interface {
+	Shutdown(waitTimeout time.Duration) error
+}
+
+// ShutdownFunc 让普通函数也能当 GracefullyShutdowner 用。
+type ShutdownFunc func(waitTimeout time.Duration) error
+
+func (f ShutdownFunc) Shutdown(waitTimeout time.Duration) error {
+	return f(waitTimeout)
+}
+
+// 并发退出：所有组件同时退出，整体不超过 waitTimeout。
+// 返回的错误合并了所有失败和超时的组件，见 ShutdownReport.Err。
+func ConcurrentShutdown(waitTimeout time.Duration, shutdowners ...GracefullyShutdowner) error {
+	return ConcurrentShutdownWithReport(waitTimeout, shutdowners...).Err()
+}
+
+// ConcurrentShutdownWithReport 和 ConcurrentShutdown 一样，但返回每个组件的退出报告。
+func ConcurrentShutdownWithReport(waitTimeout time.Duration, shutdowners ...GracefullyShutdowner) *ShutdownReport {
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	return ConcurrentShutdownContext(ctx, fromGraceful(shutdowners)...)
+}
+
+// 串行退出：按顺序依次退出，整体近似不超过 waitTimeout。
+// 某个组件超时之后，后面的组件不再退出，在报告里记为 Skipped。
+func SequentialShutdown(waitTimeout time.Duration, shutdowners ...GracefullyShutdowner) error {
+	return SequentialShutdownWithReport(waitTimeout, shutdowners...).Err()
+}
+
+// SequentialShutdownWithReport 和 SequentialShutdown 一样，但返回每个组件的退出报告。
+// 每个组件拿到的 waitTimeout 是离整体截止时间还剩下的时间。
+func SequentialShutdownWithReport(waitTimeout time.Duration, shutdowners ...GracefullyShutdowner) *ShutdownReport {
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	return SequentialShutdownContext(ctx, fromGraceful(shutdowners)...)
+}
+
+func fromGraceful(shutdowners []GracefullyShutdowner) []ContextShutdowner {
+	cs := make([]ContextShutdowner, len(shutdowners))
+	for i, s := range shutdowners {
+		cs[i] = FromGraceful(s)
+	}
+	return cs
+}
+
+var (
+	ErrDuplicateComponent = errors.New("lifecycle: component already registered")
+	ErrDependencyCycle    = errors.New("lifecycle: dependency cycle")
+	ErrUnknownDependency  = errors.New("lifecycle: unknown dependency")
+)
+
+// ShutdownGraph 按依赖关系退出的一组组件，介于 ConcurrentShutdown 和 SequentialShutdown 之间。
+//
+// A 依赖 B 表示 A 在运行时要用到 B，所以 A 必须先于 B 退出。比如 HTTP 服务依赖各个 worker，
+// worker 都依赖数据库连接池：先停 HTTP 服务，再并行停所有 worker，最后停连接池。
+// 互不依赖的组件并行退出，所有组件共用一个截止时间。
+type ShutdownGraph struct {
+	mu    sync.Mutex
+	names []string // 注册顺序
+	nodes map[string]*graphNode
+}
+
+type graphNode struct {
+	name string
+	s    ContextShutdowner
+	deps []string
+}
+
+func NewShutdownGraph() *ShutdownGraph {
+	return &ShutdownGraph{nodes: make(map[string]*graphNode)}
+}
+
+// Register 注册一个组件。dependsOn 里的组件可以稍后再注册；
+// 注册之后如果形成了环，注册失败，图保持原样。
+func (g *ShutdownGraph) Register(name string, s GracefullyShutdowner, dependsOn ...string) error {
+	return g.RegisterContext(name, FromGraceful(s), dependsOn...)
+}
+
+// RegisterContext 和 Register 一样，注册的是 ContextShutdowner。
+func (g *ShutdownGraph) RegisterContext(name string, s ContextShutdowner, dependsOn ...string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.nodes[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateComponent, name)
+	}
+
+	n := &graphNode{name: name, s: s, deps: append([]string(nil), dependsOn...)}
+	g.nodes[name] = n
+	if path := g.findCycle(name); path != nil {
+		delete(g.nodes, name)
+		return fmt.Errorf("%w: %v", ErrDependencyCycle, path)
+	}
+	g.names = append(g.names, name)
+	return nil
+}
+
+// findCycle 从 start 出发沿依赖深度优先搜索，找到回到 start 的路径就返回它。
+func (g *ShutdownGraph) findCycle(start string) []string {
+	visited := make(map[string]bool)
+	var path []string
+
+	var dfs func(name string) bool
+	dfs = func(name string) bool {
+		path = append(path, name)
+		n, ok := g.nodes[name]
+		if ok {
+			for _, dep := range n.deps {
+				if dep == start {
+					path = append(path, dep)
+					return true
+				}
+				if !visited[dep] {
+					visited[dep] = true
+					if dfs(dep) {
+						return true
+					}
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if dfs(start) {
+		return path
+	}
+	return nil
+}
+
+// Shutdown 按依赖关系退出所有组件：一个组件要等所有依赖它的组件都退出之后才开始退出。
+// 每个组件拿到的 waitTimeout 是离整体截止时间还剩下的时间。
+func (g *ShutdownGraph) Shutdown(waitTimeout time.Duration) error {
+	r, err := g.ShutdownWithReport(waitTimeout)
+	if err != nil {
+		return err
+	}
+	return r.Err()
+}
+
+// ShutdownWithReport 和 Shutdown 一样，但返回每个组件的退出报告，组件按注册顺序排列。
+// 只有依赖关系不完整时才返回错误，这时没有任何组件被退出。
+func (g *ShutdownGraph) ShutdownWithReport(waitTimeout time.Duration) (*ShutdownReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	return g.ShutdownContext(ctx)
+}
+
+// ShutdownContext 和 ShutdownWithReport 一样，截止时间由 ctx 决定。
+// ctx 结束时还在退出的组件，它们拿到的 ctx 也会被取消；还没开始退出的组件不再退出。
+func (g *ShutdownGraph) ShutdownContext(ctx context.Context) (*ShutdownReport, error) {
+	g.mu.Lock()
+	names := append([]string(nil), g.names...)
+	nodes := make(map[string]*graphNode, len(g.nodes))
+	for k, v := range g.nodes {
+		nodes[k] = v
+	}
+	g.mu.Unlock()
+
+	// dependents[b] 是所有依赖 b 的组件，b 要等它们先退出。
+	dependents := make(map[string][]string)
+	for _, name := range names {
+		for _, dep := range nodes[name].deps {
+			if _, ok := nodes[dep]; !ok {
+				return nil, fmt.Errorf("%w: %s depends on %s", ErrUnknownDependency, name, dep)
+			}
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t := newTracker(ctx, names)
+	done := make(map[string]chan struct{}, len(names))
+	for _, name := range names {
+		done[name] = make(chan struct{})
+	}
+	all := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, n *graphNode) {
+			defer wg.Done()
+			defer close(done[n.name])
+
+			for _, d := range dependents[n.name] {
+				select {
+				case <-done[d]:
+				case <-ctx.Done():
+					return // 截止时间到了，不再开始退出
+				}
+			}
+			// 等的组件刚好退出完、ctx 也刚好结束时，上面的 select 随机选一个分支；
+			// 没有要等的组件时 ctx 也可能一开始就结束了。这两种情况都不能再开始退出。
+			if ctx.Err() != nil {
+				return
+			}
+
+			t.start(i)
+			t.finish(i, n.s.Shutdown(ctx))
+		}(i, nodes[name])
+	}
+	go func() {
+		wg.Wait()
+		close(all)
+	}()
+
+	select {
+	case <-all:
+	case <-ctx.Done():
+	}
+	return t.seal(), nil
+}
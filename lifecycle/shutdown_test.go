@@ -0,0 +1,115 @@
+package lifecycle_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"CInG/lifecycle"
+)
+
+// recorder 记录组件退出的先后顺序。
+type recorder struct {
+	mu    sync.Mutex
+	start map[string]time.Time
+	end   map[string]time.Time
+}
+
+func newRecorder() *recorder {
+	return &recorder{start: make(map[string]time.Time), end: make(map[string]time.Time)}
+}
+
+func (r *recorder) component(name string, d time.Duration, err error) lifecycle.GracefullyShutdowner {
+	return lifecycle.ShutdownFunc(func(waitTimeout time.Duration) error {
+		r.mu.Lock()
+		r.start[name] = time.Now()
+		r.mu.Unlock()
+
+		time.Sleep(d)
+
+		r.mu.Lock()
+		r.end[name] = time.Now()
+		r.mu.Unlock()
+		return err
+	})
+}
+
+func TestShutdownGraphOrder(t *testing.T) {
+	r := newRecorder()
+	g := lifecycle.NewShutdownGraph()
+
+	// http -> worker1, worker2 -> db。db 最先注册，依赖可以晚注册。
+	must(t, g.Register("db", r.component("db", 10*time.Millisecond, nil)))
+	must(t, g.Register("http", r.component("http", 10*time.Millisecond, nil), "worker1", "worker2"))
+	must(t, g.Register("worker1", r.component("worker1", 30*time.Millisecond, nil), "db"))
+	must(t, g.Register("worker2", r.component("worker2", 30*time.Millisecond, nil), "db"))
+
+	begin := time.Now()
+	if err := g.Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range []string{"worker1", "worker2"} {
+		if r.start[w].Before(r.end["http"]) {
+			t.Errorf("%s started before http finished", w)
+		}
+		if r.start["db"].Before(r.end[w]) {
+			t.Errorf("db started before %s finished", w)
+		}
+	}
+	// 两个 worker 并行：总耗时约 10+30+10ms，而不是 10+30+30+10ms。
+	if elapsed := time.Since(begin); elapsed > 75*time.Millisecond {
+		t.Errorf("shutdown took %v, workers did not run in parallel", elapsed)
+	}
+}
+
+func TestShutdownGraphRejectsCycle(t *testing.T) {
+	noop := lifecycle.ShutdownFunc(func(time.Duration) error { return nil })
+	g := lifecycle.NewShutdownGraph()
+
+	must(t, g.Register("a", noop, "b"))
+	must(t, g.Register("b", noop, "c"))
+	if err := g.Register("c", noop, "a"); !errors.Is(err, lifecycle.ErrDependencyCycle) {
+		t.Fatalf("Register(c -> a) = %v, want ErrDependencyCycle", err)
+	}
+	if err := g.Register("a", noop); !errors.Is(err, lifecycle.ErrDuplicateComponent) {
+		t.Fatalf("Register(a) again = %v, want ErrDuplicateComponent", err)
+	}
+
+	// c 没有注册成功，b 的依赖找不到。
+	if err := g.Shutdown(time.Second); !errors.Is(err, lifecycle.ErrUnknownDependency) {
+		t.Fatalf("Shutdown = %v, want ErrUnknownDependency", err)
+	}
+	must(t, g.Register("c", noop))
+	must(t, g.Shutdown(time.Second))
+}
+
+func TestShutdownGraphErrorsAndDeadline(t *testing.T) {
+	r := newRecorder()
+	errClose := errors.New("close failed")
+
+	g := lifecycle.NewShutdownGraph()
+	must(t, g.Register("cache", r.component("cache", 0, errClose)))
+	must(t, g.Register("api", r.component("api", 0, nil), "cache"))
+	if err := g.Shutdown(time.Second); !errors.Is(err, errClose) {
+		t.Fatalf("Shutdown = %v, want %v", err, errClose)
+	}
+
+	g = lifecycle.NewShutdownGraph()
+	must(t, g.Register("slow", r.component("slow", 200*time.Millisecond, nil)))
+	begin := time.Now()
+	if err := g.Shutdown(20 * time.Millisecond); err == nil {
+		t.Fatal("Shutdown of a slow component returned nil")
+	}
+	if elapsed := time.Since(begin); elapsed > 100*time.Millisecond {
+		t.Errorf("Shutdown returned after %v, want about 20ms", elapsed)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
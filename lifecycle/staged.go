@@ -0,0 +1,132 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Readiness 就绪状态，零值表示已就绪。它本身就是 /readyz 的 http.Handler：
+// 就绪时返回200，未就绪时返回503，负载均衡据此摘掉流量。
+type Readiness struct {
+	notReady atomic.Bool
+}
+
+func (r *Readiness) SetReady(ready bool) {
+	r.notReady.Store(!ready)
+}
+
+func (r *Readiness) Ready() bool {
+	return !r.notReady.Load()
+}
+
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !r.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("shutting down\n"))
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// StagedShutdown 分阶段退出：
+//  1. 把 Readiness 标记为未就绪；
+//  2. 等待 Grace，让负载均衡有时间停止转发新请求；
+//  3. 并发执行 PreStop 钩子；
+//  4. 退出 Components（默认并发，Sequential 为 true 时按顺序）。
+//
+// 每个阶段有自己的时间份额：Grace、PreStopTimeout、ShutdownTimeout。
+// 为0的份额表示不单独限制，用掉前面阶段剩下的所有时间。
+// ctx 剩下的时间不够分时，还没开始的阶段按份额等比例缩短；前面阶段没用完的时间留给后面的阶段。
+type StagedShutdown struct {
+	Readiness *Readiness // 为nil时跳过第1步
+	Grace     time.Duration
+
+	PreStop        []ContextShutdowner
+	PreStopTimeout time.Duration
+
+	Components      []ContextShutdowner
+	Sequential      bool
+	ShutdownTimeout time.Duration
+}
+
+// StagedReport 分阶段退出的报告。
+type StagedReport struct {
+	Grace      time.Duration // 实际等待的时间，ctx 提前结束时会比 StagedShutdown.Grace 短
+	PreStop    *ShutdownReport
+	Components *ShutdownReport
+	Elapsed    time.Duration
+}
+
+// Err 合并 PreStop 钩子和组件的退出错误。
+func (r *StagedReport) Err() error {
+	return errors.Join(r.PreStop.Err(), r.Components.Err())
+}
+
+// Shutdown 按阶段退出，整体不超过 ctx 的截止时间。
+// PreStop 钩子失败或超时不会阻止后面的阶段，组件总是会被退出。
+func (s *StagedShutdown) Shutdown(ctx context.Context) *StagedReport {
+	begin := time.Now()
+	r := &StagedReport{}
+
+	if s.Readiness != nil {
+		s.Readiness.SetReady(false)
+	}
+
+	grace := phaseBudget(ctx, s.Grace, s.Grace, s.PreStopTimeout, s.ShutdownTimeout)
+	if grace > 0 {
+		t := time.NewTimer(grace)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+		}
+	}
+	r.Grace = time.Since(begin)
+
+	pctx, cancel := phaseContext(ctx, phaseBudget(ctx, s.PreStopTimeout, s.PreStopTimeout, s.ShutdownTimeout))
+	r.PreStop = ConcurrentShutdownContext(pctx, s.PreStop...)
+	cancel()
+
+	cctx, cancel := phaseContext(ctx, phaseBudget(ctx, s.ShutdownTimeout, s.ShutdownTimeout))
+	if s.Sequential {
+		r.Components = SequentialShutdownContext(cctx, s.Components...)
+	} else {
+		r.Components = ConcurrentShutdownContext(cctx, s.Components...)
+	}
+	cancel()
+
+	r.Elapsed = time.Since(begin)
+	return r
+}
+
+// phaseBudget 计算当前阶段能用的时间。want 是当前阶段的份额，rest 是当前和之后所有阶段的份额；
+// 返回0表示不单独限制。
+func phaseBudget(ctx context.Context, want time.Duration, rest ...time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok || want <= 0 {
+		return want
+	}
+	var total time.Duration
+	for _, d := range rest {
+		total += max(d, 0)
+	}
+	left := time.Until(deadline)
+	if left >= total {
+		return want
+	}
+	if left <= 0 {
+		return time.Nanosecond // 已经到截止时间了，不能返回0（0表示不限制）
+	}
+	return time.Duration(float64(want) * float64(left) / float64(total))
+}
+
+func phaseContext(ctx context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, budget)
+}
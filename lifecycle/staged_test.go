@@ -0,0 +1,102 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"CInG/lifecycle"
+)
+
+func readyz(r *lifecycle.Readiness) int {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	return w.Code
+}
+
+func TestStagedShutdownPhases(t *testing.T) {
+	var ready lifecycle.Readiness
+	if code := readyz(&ready); code != http.StatusOK {
+		t.Fatalf("readyz before shutdown = %d, want 200", code)
+	}
+
+	var mu sync.Mutex
+	var events []string
+	at := make(map[string]time.Duration)
+	begin := time.Now()
+	phase := func(name string) lifecycle.ContextShutdowner {
+		return lifecycle.ContextShutdownFunc(func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, name)
+			at[name] = time.Since(begin)
+			if code := readyz(&ready); code != http.StatusServiceUnavailable {
+				t.Errorf("readyz during %s = %d, want 503", name, code)
+			}
+			return nil
+		})
+	}
+
+	s := &lifecycle.StagedShutdown{
+		Readiness:  &ready,
+		Grace:      50 * time.Millisecond,
+		PreStop:    []lifecycle.ContextShutdowner{phase("pre-stop")},
+		Components: []lifecycle.ContextShutdowner{phase("db"), phase("http")},
+		Sequential: true,
+	}
+	r := s.Shutdown(context.Background())
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"pre-stop", "db", "http"}
+	if len(events) != len(want) || events[0] != want[0] || events[1] != want[1] || events[2] != want[2] {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if at["pre-stop"] < 50*time.Millisecond {
+		t.Errorf("pre-stop ran after %v, want after the 50ms grace period", at["pre-stop"])
+	}
+}
+
+func TestStagedShutdownPhaseBudgets(t *testing.T) {
+	var deadlineLeft time.Duration
+	s := &lifecycle.StagedShutdown{
+		Grace: 100 * time.Millisecond,
+		PreStop: []lifecycle.ContextShutdowner{lifecycle.ContextShutdownFunc(func(ctx context.Context) error {
+			<-ctx.Done() // 卡住的钩子只会用掉自己那一份时间
+			return ctx.Err()
+		})},
+		PreStopTimeout: 100 * time.Millisecond,
+		Components: []lifecycle.ContextShutdowner{lifecycle.ContextShutdownFunc(func(ctx context.Context) error {
+			d, _ := ctx.Deadline()
+			deadlineLeft = time.Until(d)
+			return nil
+		})},
+		ShutdownTimeout: 100 * time.Millisecond,
+	}
+
+	// 总时间只有份额之和的一半，每个阶段都按比例缩短到50ms左右。
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	r := s.Shutdown(ctx)
+
+	if r.Grace < 30*time.Millisecond || r.Grace > 80*time.Millisecond {
+		t.Errorf("grace = %v, want about 50ms", r.Grace)
+	}
+	if got := r.PreStop.TimedOut(); len(got) != 1 {
+		t.Errorf("pre-stop timed out = %v, want the stuck hook", got)
+	}
+	if r.Components.Components[0].Skipped || r.Components.Components[0].TimedOut {
+		t.Errorf("component did not get its own slice: %+v", r.Components.Components[0])
+	}
+	if deadlineLeft < 20*time.Millisecond {
+		t.Errorf("component got %v before its deadline, want about 50ms", deadlineLeft)
+	}
+	if !errors.Is(r.Err(), lifecycle.ErrTimeout) {
+		t.Errorf("err = %v, want the pre-stop timeout", r.Err())
+	}
+}
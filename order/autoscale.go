@@ -0,0 +1,114 @@
+package order
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// AutoscalePolicy 按队列深度自动伸缩消费者数量。
+// 每隔 Interval 检查一次队列长度：连续 Sustain 次高于 HighWater 就增加 Step 个消费者（不超过 Max），
+// 连续 Sustain 次低于 LowWater 就让 Step 个空闲的消费者退出（不少于 Min）。
+// 正在处理订单的消费者不会被缩掉。
+type AutoscalePolicy struct {
+	Min, Max  int
+	HighWater int
+	LowWater  int
+	Interval  time.Duration // 0表示500ms
+	Sustain   int           // 小于1时按1处理
+	Step      int           // 小于1时按1处理
+
+	Logger *log.Logger // 记录伸缩事件，为nil时使用 log 包的默认 Logger
+}
+
+// WithAutoscale 打开自动伸缩。NewProcessor 的 workers 参数作为初始消费者数，会被限制在 [Min, Max] 之内。
+func WithAutoscale(ap AutoscalePolicy) Option {
+	return func(p *Processor) {
+		ap.Min = max(ap.Min, 1)
+		ap.Max = max(ap.Max, ap.Min)
+		if ap.Interval <= 0 {
+			ap.Interval = 500 * time.Millisecond
+		}
+		ap.Sustain = max(ap.Sustain, 1)
+		ap.Step = max(ap.Step, 1)
+
+		p.autoscale = &ap
+		p.workers = min(max(p.workers, ap.Min), ap.Max)
+		p.shrink = make(chan struct{}) // 无缓冲：只有正在等订单的消费者才能收到
+	}
+}
+
+func (ap *AutoscalePolicy) logf(format string, args ...any) {
+	if ap.Logger != nil {
+		ap.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// spawnConsumer 启动一个新的消费者。调用方需要保证 p.wg 此时不为0（Start 里或者伸缩goroutine里）。
+func (p *Processor) spawnConsumer(ctx context.Context) {
+	p.mu.Lock()
+	p.lastID++
+	id := p.lastID
+	p.mu.Unlock()
+
+	p.metrics.consumers.Add(1)
+	p.wg.Add(1)
+	go p.consume(ctx, id)
+}
+
+// scale 伸缩goroutine。订单源产完之后就不再伸缩，剩下的消费者把队列处理完自然退出。
+func (p *Processor) scale(ctx context.Context) {
+	defer p.wg.Done()
+
+	ap := p.autoscale
+	ticker := time.NewTicker(ap.Interval)
+	defer ticker.Stop()
+
+	var above, below int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.produced:
+			return
+		case <-ticker.C:
+		}
+
+		depth := p.QueueLen()
+		switch {
+		case depth > ap.HighWater:
+			above, below = above+1, 0
+		case depth < ap.LowWater:
+			above, below = 0, below+1
+		default:
+			above, below = 0, 0
+		}
+
+		n := int(p.metrics.consumers.Load())
+		if above >= ap.Sustain && n < ap.Max {
+			add := min(ap.Step, ap.Max-n)
+			for range add {
+				p.spawnConsumer(ctx)
+			}
+			above = 0
+			ap.logf("order: scale up %d -> %d consumers (queue %d > high water %d)", n, n+add, depth, ap.HighWater)
+		}
+
+		if below >= ap.Sustain && n > ap.Min {
+			removed := 0
+			for range min(ap.Step, n-ap.Min) {
+				select {
+				case p.shrink <- struct{}{}:
+					removed++
+				default: // 没有空闲的消费者
+				}
+			}
+			below = 0
+			if removed > 0 {
+				ap.logf("order: scale down %d -> %d consumers (queue %d < low water %d)", n, n-removed, depth, ap.LowWater)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package order_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+func TestProcessorAutoscale(t *testing.T) {
+	// 先一口气产出40个订单，然后停住，直到 release 被关闭。
+	release := make(chan struct{})
+	next := 0
+	src := order.SourceFunc(func(ctx context.Context) (order.Order, error) {
+		if next == 40 {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return order.Order{}, ctx.Err()
+			}
+			return order.Order{}, io.EOF
+		}
+		next++
+		return order.Order{ID: next}, nil
+	})
+	handler := func(ctx context.Context, o order.Order) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	var logs bytes.Buffer
+	p := order.NewProcessor(src, handler, 1, 50, order.WithAutoscale(order.AutoscalePolicy{
+		Min: 1, Max: 4,
+		HighWater: 5, LowWater: 1,
+		Interval: 5 * time.Millisecond,
+		Logger:   log.New(&logs, "", 0),
+	}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	peak := 0
+	deadline := time.After(5 * time.Second)
+	for done := 0; done < 40; {
+		select {
+		case <-p.Results():
+			done++
+			peak = max(peak, p.Metrics().Consumers)
+		case <-deadline:
+			t.Fatal("orders not processed in time")
+		}
+	}
+	if peak < 2 || peak > 4 {
+		t.Errorf("peak consumers = %d, want within [2, 4]", peak)
+	}
+
+	// 队列空了，空闲的消费者应该被缩回到 Min。
+	for p.Metrics().Consumers > 1 {
+		select {
+		case <-deadline:
+			t.Fatalf("consumers = %d, want scaled down to 1", p.Metrics().Consumers)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	// 退出的消费者不能留在 order_in_flight 里。
+	if m := p.Metrics(); len(m.InFlight) != 1 {
+		t.Errorf("InFlight = %v after scaling down, want only the remaining consumer", m.InFlight)
+	}
+
+	close(release)
+	for range p.Results() {
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, "scale up") || !strings.Contains(out, "scale down") {
+		t.Errorf("scale events not logged:\n%s", out)
+	}
+}
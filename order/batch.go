@@ -0,0 +1,146 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"CInG/join"
+)
+
+// ErrBatchResult BatchHandler 返回的切片不是nil，长度却和订单数不一致。这时无法判断哪些订单成功了，整批都算失败。
+var ErrBatchResult = errors.New("order: batch handler returned wrong number of errors")
+
+// BatchHandler 一次处理一批订单。返回的切片和 orders 一一对应，nil 表示该订单处理成功；
+// 整个返回值为 nil 表示全部成功。切片不为nil但长度不对时，整批订单都以 ErrBatchResult 失败。
+type BatchHandler func(ctx context.Context, orders []Order) []error
+
+type batching struct {
+	size    int
+	maxWait time.Duration
+	handler BatchHandler
+}
+
+// WithBatching 打开批处理：每个消费者攒够 size 个订单，或者从拿到第一个订单起等了 maxWait，
+// 就把这一批交给 h。此时 NewProcessor 的 handler 不会被调用，可以传nil。
+// 每个订单仍然单独出现在 Results 里；失败的订单按重试策略单独组成一批重试。
+func WithBatching(size int, maxWait time.Duration, h BatchHandler) Option {
+	return func(p *Processor) {
+		p.batch = &batching{size: max(size, 1), maxWait: maxWait, handler: h}
+	}
+}
+
+func (p *Processor) consumeBatches(ctx context.Context, id int, l *lanes) {
+	for !l.stopped {
+		o, ok := l.next(ctx, nil)
+		if !ok {
+			return
+		}
+
+		var batch []Order
+		keep, alive := p.admit(ctx, id, o)
+		if !alive {
+			return
+		}
+		if keep {
+			batch = append(batch, o)
+		}
+
+		timer := time.NewTimer(p.batch.maxWait)
+		for len(batch) < p.batch.size {
+			o, ok := l.next(ctx, timer.C)
+			if !ok {
+				break // 超时、通道关闭或者要退出，先把手上这一批处理掉
+			}
+			keep, alive := p.admit(ctx, id, o)
+			if !alive {
+				timer.Stop()
+				p.abandonClaimed(batch...)
+				return
+			}
+			if keep {
+				batch = append(batch, o)
+			}
+		}
+		timer.Stop()
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		start := time.Now()
+		p.metrics.addInFlight(id, int64(len(batch)))
+		done := p.handleBatch(ctx, batch)
+		p.metrics.addInFlight(id, -int64(len(batch)))
+
+		if ctx.Err() != nil {
+			p.abandonClaimed(done...)
+			return
+		}
+		d := time.Since(start)
+		for i, o := range done {
+			if !p.complete(ctx, id, o, d) {
+				p.abandonClaimed(done[i+1:]...) // complete 自己处理了 o，后面的还没确认
+				return
+			}
+		}
+	}
+}
+
+// handleBatch 和 handle 一样按重试策略处理，只是以批为单位：每一轮把还需要重试的订单一起交给 BatchHandler。
+func (p *Processor) handleBatch(ctx context.Context, batch []Order) []Order {
+	done := make([]Order, 0, len(batch))
+	pending := batch
+
+	for len(pending) > 0 {
+		for i := range pending {
+			pending[i].Attempts++
+		}
+		errs := p.callBatchHandler(ctx, pending)
+
+		var retry []Order
+		for i, o := range pending {
+			o.LastErr = nil
+			if errs != nil {
+				o.LastErr = errs[i]
+			}
+			if o.LastErr != nil && o.Attempts < p.retry.MaxAttempts && p.retry.retryable(o.LastErr) {
+				retry = append(retry, o)
+				continue
+			}
+			done = append(done, o)
+		}
+
+		if len(retry) > 0 && sleep(ctx, p.retry.Backoff(retry[0].Attempts)) != nil {
+			return append(done, retry...)
+		}
+		pending = retry
+	}
+	return done
+}
+
+// callBatchHandler 调用 BatchHandler，返回nil或者和 orders 等长的切片。
+// 发生 panic 时这一批订单都算失败，错误是同一个 *join.PanicError；返回的切片长度不对时错误是 ErrBatchResult。
+func (p *Processor) callBatchHandler(ctx context.Context, orders []Order) (errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			errs = failAll(len(orders), &join.PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+
+	errs = p.batch.handler(ctx, orders)
+	if errs != nil && len(errs) != len(orders) {
+		return failAll(len(orders), fmt.Errorf("%w: got %d errors for %d orders", ErrBatchResult, len(errs), len(orders)))
+	}
+	return errs
+}
+
+func failAll(n int, err error) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
@@ -0,0 +1,139 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+func TestProcessorBatching(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	failedOnce := make(map[int]bool)
+
+	// 奇数订单第一次失败，重试一次后成功。
+	bh := func(ctx context.Context, orders []order.Order) []error {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, len(orders))
+
+		errs := make([]error, len(orders))
+		for i, o := range orders {
+			if o.ID%2 == 1 && !failedOnce[o.ID] {
+				failedOnce[o.ID] = true
+				errs[i] = errors.New("gateway busy")
+			}
+		}
+		return errs
+	}
+
+	p := order.NewProcessor(order.SliceSource(makeOrders(10)...), nil, 1, 10,
+		order.WithBatching(4, 200*time.Millisecond, bh),
+		order.WithRetry(order.RetryPolicy{MaxAttempts: 2}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(map[int]order.Result)
+	for r := range p.Results() {
+		results[r.Order.ID] = r
+	}
+
+	if len(results) != 10 {
+		t.Fatalf("got %d results, want 10", len(results))
+	}
+	for id, r := range results {
+		wantAttempts := 1
+		if id%2 == 1 {
+			wantAttempts = 2
+		}
+		if r.Err != nil || r.Order.Attempts != wantAttempts {
+			t.Errorf("order #%d: err=%v attempts=%d, want success after %d", id, r.Err, r.Order.Attempts, wantAttempts)
+		}
+	}
+
+	// 10个订单按4个一批：4+4+2，另外每批里失败的奇数订单各重试一批。
+	total, full := 0, 0
+	for _, n := range sizes {
+		if n > 4 {
+			t.Errorf("batch of %d orders, want at most 4", n)
+		}
+		if n == 4 {
+			full++
+		}
+		total += n
+	}
+	if total != 15 || full == 0 {
+		t.Errorf("batch sizes = %v, want 15 orders in total with at least one full batch", sizes)
+	}
+}
+
+func TestProcessorBatchFlushOnTimeout(t *testing.T) {
+	release := make(chan struct{})
+	next := 0
+	src := order.SourceFunc(func(ctx context.Context) (order.Order, error) {
+		if next == 2 {
+			<-release
+			return order.Order{}, io.EOF
+		}
+		next++
+		return order.Order{ID: next}, nil
+	})
+
+	batches := make(chan int, 10)
+	bh := func(ctx context.Context, orders []order.Order) []error {
+		batches <- len(orders)
+		return nil
+	}
+
+	p := order.NewProcessor(src, nil, 1, 10, order.WithBatching(10, 20*time.Millisecond, bh))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case n := <-batches:
+		if n != 2 {
+			t.Errorf("first batch has %d orders, want 2", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("batch not flushed after max wait")
+	}
+
+	close(release)
+	for range p.Results() {
+	}
+}
+
+func TestProcessorBatchWrongErrorCount(t *testing.T) {
+	// 有问题的网关适配器：3个订单只返回了2个错误，第3个订单不能被当成支付成功。
+	var calls atomic.Int32
+	bh := func(ctx context.Context, orders []order.Order) []error {
+		calls.Add(1)
+		return make([]error, len(orders)-1)
+	}
+
+	p := order.NewProcessor(order.SliceSource(makeOrders(3)...), nil, 1, 3,
+		order.WithBatching(3, time.Second, bh),
+		order.WithRetry(order.RetryPolicy{MaxAttempts: 3}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for r := range p.Results() {
+		n++
+		if !errors.Is(r.Err, order.ErrBatchResult) || r.Order.Attempts != 1 {
+			t.Errorf("order #%d: err=%v attempts=%d, want ErrBatchResult without retry", r.Order.ID, r.Err, r.Order.Attempts)
+		}
+	}
+	if n != 3 || calls.Load() != 1 {
+		t.Errorf("got %d results from %d batch calls, want 3 from 1", n, calls.Load())
+	}
+}
@@ -0,0 +1,102 @@
+package order
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Deduper 记录最近处理成功的订单ID，用来跳过重试、日志重放带来的重复订单，避免重复扣款。
+// 最多记 maxSize 个ID，超出时淘汰最早的；每个ID记录 ttl 时间后过期。
+// 正在处理中的订单也算"见过"，两个消费者同时拿到同一个ID时只有一个会真正处理。
+type Deduper struct {
+	mu       sync.Mutex
+	maxSize  int
+	ttl      time.Duration
+	done     map[int]*list.Element // ID -> lru 里的元素
+	lru      *list.List            // 按处理完成的时间排序，最早的在前面
+	inFlight map[int]struct{}
+}
+
+type dedupEntry struct {
+	id int
+	at time.Time
+}
+
+// NewDeduper maxSize 小于1时按1024处理，ttl 为0表示不过期。
+func NewDeduper(maxSize int, ttl time.Duration) *Deduper {
+	if maxSize < 1 {
+		maxSize = 1024
+	}
+	return &Deduper{
+		maxSize:  maxSize,
+		ttl:      ttl,
+		done:     make(map[int]*list.Element),
+		lru:      list.New(),
+		inFlight: make(map[int]struct{}),
+	}
+}
+
+// WithDedup 在处理订单之前先查 d，重复的订单不再交给 Handler，
+// 直接以 Duplicate 结果出现在 Results 里，并计入 Snapshot.Duplicates。
+func WithDedup(d *Deduper) Option {
+	return func(p *Processor) {
+		p.dedup = d
+	}
+}
+
+// Claim 订单没见过时占住该ID并返回 true；处理完之后必须调用 Release。
+// 订单最近处理成功过或者正在处理中时返回 false。
+func (d *Deduper) Claim(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.inFlight[id]; ok {
+		return false
+	}
+	if e, ok := d.done[id]; ok {
+		if !d.expired(e.Value.(dedupEntry), time.Now()) {
+			return false
+		}
+		d.remove(e)
+	}
+
+	d.inFlight[id] = struct{}{}
+	return true
+}
+
+// Release 释放 Claim 占住的ID。ok 为 true 表示处理成功，ID会被记住；
+// 失败的订单不记，之后重试或重放时还能再处理。
+func (d *Deduper) Release(id int, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, id)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	d.done[id] = d.lru.PushBack(dedupEntry{id: id, at: now})
+
+	// 先清掉过期的，再按容量淘汰最早的。
+	for e := d.lru.Front(); e != nil && (d.lru.Len() > d.maxSize || d.expired(e.Value.(dedupEntry), now)); e = d.lru.Front() {
+		d.remove(e)
+	}
+}
+
+// Len 当前记住的处理成功的ID数。
+func (d *Deduper) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lru.Len()
+}
+
+func (d *Deduper) expired(e dedupEntry, now time.Time) bool {
+	return d.ttl > 0 && now.Sub(e.at) >= d.ttl
+}
+
+func (d *Deduper) remove(e *list.Element) {
+	d.lru.Remove(e)
+	delete(d.done, e.Value.(dedupEntry).id)
+}
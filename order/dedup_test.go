@@ -0,0 +1,75 @@
+package order_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+func TestDeduper(t *testing.T) {
+	d := order.NewDeduper(2, 50*time.Millisecond)
+
+	if !d.Claim(1) {
+		t.Fatal("first Claim(1) = false")
+	}
+	if d.Claim(1) {
+		t.Fatal("Claim(1) while in flight = true")
+	}
+	d.Release(1, false)
+	if !d.Claim(1) {
+		t.Fatal("Claim(1) after failed attempt = false")
+	}
+	d.Release(1, true)
+	if d.Claim(1) {
+		t.Fatal("Claim(1) after success = true")
+	}
+
+	// 容量为2，记住3个之后最早的1被淘汰。
+	for _, id := range []int{2, 3} {
+		d.Claim(id)
+		d.Release(id, true)
+	}
+	if d.Len() != 2 {
+		t.Errorf("Len = %d, want 2", d.Len())
+	}
+	if !d.Claim(1) {
+		t.Error("Claim(1) after eviction = false")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !d.Claim(3) {
+		t.Error("Claim(3) after ttl = false")
+	}
+}
+
+func TestProcessorSkipsDuplicates(t *testing.T) {
+	orders := append(makeOrders(5), makeOrders(3)...) // 1-3 各出现两次
+
+	var charged atomic.Int32
+	handler := func(ctx context.Context, o order.Order) error {
+		charged.Add(1)
+		return nil
+	}
+
+	p := order.NewProcessor(order.SliceSource(orders...), handler, 1, 10,
+		order.WithDedup(order.NewDeduper(100, time.Minute)))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	dup := 0
+	for r := range p.Results() {
+		if r.Duplicate {
+			dup++
+		}
+	}
+	if charged.Load() != 5 || dup != 3 {
+		t.Errorf("charged %d times, %d duplicates; want 5 and 3", charged.Load(), dup)
+	}
+	if s := p.Metrics(); s.Duplicates != 3 {
+		t.Errorf("Snapshot.Duplicates = %d, want 3", s.Duplicates)
+	}
+}
@@ -0,0 +1,96 @@
+package order
+
+import "context"
+
+// StopSummary 停止之后的汇总。
+type StopSummary struct {
+	Processed  int64   // 处理结束的订单数，包括失败的
+	Unfinished []Order // Handler 被打断、或者还留在队列里没处理的订单；打开日志时它们下次启动会被重新处理
+	TimedOut   bool    // Drain 在截止时间之前没处理完，退化成了 HardStop
+}
+
+// Drain 优雅停止：先停止接收新订单，让消费者把队列里已有的订单处理完。
+// ctx 到期（或者被取消）时还没处理完，就退化成 HardStop。
+// Drain 期间调用方仍然需要读取 Results，否则消费者会阻塞在写结果上。
+func (p *Processor) Drain(ctx context.Context) StopSummary {
+	p.mu.Lock()
+	stopIntake := p.stopIntake
+	p.mu.Unlock()
+	if stopIntake == nil {
+		return StopSummary{}
+	}
+
+	stopIntake()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return p.summary()
+	case <-ctx.Done():
+		s := p.HardStop()
+		s.TimedOut = true
+		return s
+	}
+}
+
+// HardStop 强制停止：取消所有 Handler 的 ctx，等所有goroutine退出，
+// 返回被打断的订单和还留在队列里的订单。可以重复调用。
+func (p *Processor) HardStop() StopSummary {
+	p.mu.Lock()
+	cancel := p.cancel
+	p.mu.Unlock()
+	if cancel == nil {
+		return StopSummary{}
+	}
+
+	cancel()
+	p.wg.Wait()
+	return p.summary()
+}
+
+// summary 只能在所有goroutine都退出之后调用，这时生产者已经关闭了队列。
+// 取队列时也持有锁：Drain 超时和强制退出可能同时调用 HardStop，两边都要拿到完整的未完成列表。
+func (p *Processor) summary() StopSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 队列已经关闭，range 会把剩下的订单取完然后结束。
+	if p.high != nil {
+		for o := range p.high {
+			p.abandoned = append(p.abandoned, o)
+		}
+	}
+	for o := range p.queue {
+		p.abandoned = append(p.abandoned, o)
+	}
+
+	return StopSummary{
+		Processed:  p.metrics.processed.Load(),
+		Unfinished: append([]Order(nil), p.abandoned...),
+	}
+}
+
+// abandonClaimed 放弃 admit 之后还没处理完的订单。除了记下来，还要释放 Deduper 里占住的ID，
+// 否则共用同一个 Deduper 的新 Processor 重放这些订单时，会把它们当成重复订单跳过。
+func (p *Processor) abandonClaimed(orders ...Order) {
+	if p.dedup != nil {
+		for _, o := range orders {
+			p.dedup.Release(o.ID, false)
+		}
+	}
+	p.abandon(orders...)
+}
+
+func (p *Processor) abandon(orders ...Order) {
+	if len(orders) == 0 {
+		return
+	}
+	p.mu.Lock()
+	p.abandoned = append(p.abandoned, orders...)
+	p.mu.Unlock()
+}
@@ -0,0 +1,153 @@
+package order_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+// endlessSource 永远有新订单，只能靠停止来结束。
+func endlessSource() order.OrderSource {
+	id := 0
+	return order.SourceFunc(func(ctx context.Context) (order.Order, error) {
+		id++
+		return order.Order{ID: id}, nil
+	})
+}
+
+func TestProcessorDrain(t *testing.T) {
+	handler := func(ctx context.Context, o order.Order) error {
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	}
+	p := order.NewProcessor(endlessSource(), handler, 3, 10)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	results := 0
+	collected := make(chan struct{})
+	go func() {
+		for range p.Results() {
+			results++
+		}
+		close(collected)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s := p.Drain(ctx)
+	<-collected
+
+	if s.TimedOut || len(s.Unfinished) != 0 {
+		t.Errorf("drain: timed out=%v, %d unfinished; want a clean drain", s.TimedOut, len(s.Unfinished))
+	}
+	if m := p.Metrics(); m.Enqueued != s.Processed || int64(results) != s.Processed {
+		t.Errorf("enqueued=%d processed=%d results=%d, want all equal", m.Enqueued, s.Processed, results)
+	}
+}
+
+func TestProcessorDrainTimeoutFallsBackToHardStop(t *testing.T) {
+	// Handler 只有被取消才会返回。
+	handler := func(ctx context.Context, o order.Order) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	p := order.NewProcessor(endlessSource(), handler, 2, 5)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for p.Metrics().QueueLen < 5 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	s := p.Drain(ctx)
+
+	if !s.TimedOut || s.Processed != 0 {
+		t.Errorf("timed out=%v processed=%d, want timed out with nothing processed", s.TimedOut, s.Processed)
+	}
+	// 2个在处理中被打断，5个还在队列里，还有1个在生产者手上等队列空位。
+	if len(s.Unfinished) != 8 {
+		t.Errorf("got %d unfinished orders, want 8", len(s.Unfinished))
+	}
+	seen := make(map[int]bool)
+	for _, o := range s.Unfinished {
+		if seen[o.ID] {
+			t.Errorf("order #%d reported twice", o.ID)
+		}
+		seen[o.ID] = true
+	}
+
+	if again := p.HardStop(); len(again.Unfinished) != 8 {
+		t.Errorf("second HardStop reported %d unfinished, want 8", len(again.Unfinished))
+	}
+}
+
+func TestHardStopReleasesDedupClaims(t *testing.T) {
+	// Handler 只有被取消才会返回。
+	handler := func(ctx context.Context, o order.Order) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	batchHandler := func(ctx context.Context, orders []order.Order) []error {
+		<-ctx.Done()
+		return []error{ctx.Err()}
+	}
+
+	for name, opts := range map[string][]order.Option{
+		"single": nil,
+		"batch":  {order.WithBatching(1, time.Second, batchHandler)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := order.NewDeduper(10, time.Minute)
+			p := order.NewProcessor(order.SliceSource(order.Order{ID: 7}), handler, 1, 1,
+				append(opts, order.WithDedup(d))...)
+			if err := p.Start(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			for p.Metrics().InFlight[1] == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			s := p.HardStop()
+			if len(s.Unfinished) != 1 || s.Unfinished[0].ID != 7 {
+				t.Fatalf("unfinished = %v, want order #7", s.Unfinished)
+			}
+			// 日志把订单7重放给共用这个 Deduper 的新 Processor 时，它不能被当成重复订单。
+			if !d.Claim(7) {
+				t.Error("Claim(7) after HardStop = false, want the abandoned order released")
+			}
+		})
+	}
+}
+
+func TestConcurrentHardStopsReportSameOrders(t *testing.T) {
+	handler := func(ctx context.Context, o order.Order) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	p := order.NewProcessor(endlessSource(), handler, 1, 5)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for p.Metrics().QueueLen < 5 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 比如 Drain 超时退化成 HardStop 的同时，第二次 Ctrl+C 也调用了 HardStop。
+	summaries := make(chan order.StopSummary, 2)
+	for range 2 {
+		go func() { summaries <- p.HardStop() }()
+	}
+	// 1个在处理中被打断，5个还在队列里，还有1个在生产者手上等队列空位。
+	for range 2 {
+		if s := <-summaries; len(s.Unfinished) != 7 {
+			t.Errorf("HardStop reported %d unfinished, want 7", len(s.Unfinished))
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package order
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultCatalog pc_model.go 里原来写死的商品列表。
+var DefaultCatalog = []string{"T-Shirt", "Laptop", "Book", "Headphones", "Camera"}
+
+// AmountDist 订单金额分布。
+type AmountDist func(r *rand.Rand) float64
+
+// UniformAmount [min, max) 上的均匀分布。
+func UniformAmount(min, max float64) AmountDist {
+	return func(r *rand.Rand) float64 {
+		return min + r.Float64()*(max-min)
+	}
+}
+
+// NormalAmount 正态分布，结果不小于 floor。
+func NormalAmount(mean, stddev, floor float64) AmountDist {
+	return func(r *rand.Rand) float64 {
+		return math.Max(floor, mean+r.NormFloat64()*stddev)
+	}
+}
+
+// ArrivalDist 相邻两个订单之间的到达间隔分布。可以带状态（比如 BurstyArrival），
+// 所以每个 Generator 要用自己的一份。
+type ArrivalDist func(r *rand.Rand) time.Duration
+
+// UniformArrival [min, max) 上的均匀间隔。
+func UniformArrival(min, max time.Duration) ArrivalDist {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int64N(int64(max-min)))
+	}
+}
+
+// PoissonArrival 泊松到达：平均每秒 ratePerSec 个订单，间隔服从指数分布。
+func PoissonArrival(ratePerSec float64) ArrivalDist {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(r.ExpFloat64() / ratePerSec * float64(time.Second))
+	}
+}
+
+// BurstyArrival 突发流量：每 burst 个订单为一组，组内间隔在 [0, gap) 之间，组与组之间空闲 idle。
+func BurstyArrival(burst int, gap, idle time.Duration) ArrivalDist {
+	burst = max(burst, 1)
+	n := 0
+	return func(r *rand.Rand) time.Duration {
+		n++
+		if n > 1 && (n-1)%burst == 0 {
+			return idle
+		}
+		if gap <= 0 {
+			return 0
+		}
+		return time.Duration(r.Int64N(int64(gap)))
+	}
+}
+
+// GeneratorConfig 订单生成器配置，零值字段使用 pc_model.go 原来的行为。
+type GeneratorConfig struct {
+	Seed        uint64
+	FirstID     int         // 第一个订单的ID，0表示1
+	Count       int         // 生成多少个订单，0表示不限
+	Catalog     []string    // 默认 DefaultCatalog
+	MaxItems    int         // 每个订单1-MaxItems个商品，默认3
+	Amount      AmountDist  // 默认 UniformAmount(50, 550)
+	Arrival     ArrivalDist // 默认 UniformArrival(0, 150ms)
+	ExpressRate float64     // 加急订单比例
+}
+
+// Arrival 工作负载中的一项：等待 Delay 之后到达的订单。
+type Arrival struct {
+	Delay time.Duration `json:"delay"`
+	Order Order         `json:"order"`
+}
+
+// Generator 可复现的订单生成器：同样的配置和种子总是产生同样的订单序列和到达间隔。
+// 它本身也是一个 OrderSource，Next 会按到达间隔睡眠之后再返回订单。
+type Generator struct {
+	cfg    GeneratorConfig
+	r      *rand.Rand
+	nextID int
+	made   int
+}
+
+func NewGenerator(cfg GeneratorConfig) *Generator {
+	if cfg.FirstID == 0 {
+		cfg.FirstID = 1
+	}
+	if len(cfg.Catalog) == 0 {
+		cfg.Catalog = DefaultCatalog
+	}
+	if cfg.MaxItems < 1 {
+		cfg.MaxItems = 3
+	}
+	cfg.MaxItems = min(cfg.MaxItems, len(cfg.Catalog))
+	if cfg.Amount == nil {
+		cfg.Amount = UniformAmount(50, 550)
+	}
+	if cfg.Arrival == nil {
+		cfg.Arrival = UniformArrival(0, 150*time.Millisecond)
+	}
+
+	return &Generator{
+		cfg:    cfg,
+		r:      rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15)),
+		nextID: cfg.FirstID,
+	}
+}
+
+// NextArrival 生成下一个订单和它的到达间隔，不睡眠。订单数达到 Count 时返回 false。
+func (g *Generator) NextArrival() (Arrival, bool) {
+	if g.cfg.Count > 0 && g.made >= g.cfg.Count {
+		return Arrival{}, false
+	}
+	g.made++
+
+	// 和原来的 generateOrder 一样：打乱商品列表取前几个。复制一份，不改调用方的 Catalog。
+	items := append([]string(nil), g.cfg.Catalog...)
+	g.r.Shuffle(len(items), func(i, j int) {
+		items[i], items[j] = items[j], items[i]
+	})
+
+	o := Order{
+		ID:      g.nextID,
+		Amount:  math.Round(g.cfg.Amount(g.r)*100) / 100,
+		Items:   items[:g.r.IntN(g.cfg.MaxItems)+1],
+		Express: g.r.Float64() < g.cfg.ExpressRate,
+	}
+	g.nextID++
+	return Arrival{Delay: g.cfg.Arrival(g.r), Order: o}, true
+}
+
+// Workload 一次生成 n 个到达事件，方便写到文件里。
+func (g *Generator) Workload(n int) []Arrival {
+	var arrivals []Arrival
+	for range n {
+		a, ok := g.NextArrival()
+		if !ok {
+			break
+		}
+		arrivals = append(arrivals, a)
+	}
+	return arrivals
+}
+
+func (g *Generator) Next(ctx context.Context) (Order, error) {
+	a, ok := g.NextArrival()
+	if !ok {
+		return Order{}, io.EOF
+	}
+	if err := sleep(ctx, a.Delay); err != nil {
+		return Order{}, err
+	}
+	return a.Order, nil
+}
+
+// WriteWorkload 把到达事件写成 JSON lines。
+func WriteWorkload(w io.Writer, arrivals []Arrival) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, a := range arrivals {
+		if err := enc.Encode(a); err != nil {
+			return fmt.Errorf("workload: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadWorkload 读出 WriteWorkload 写的到达事件。
+func ReadWorkload(r io.Reader) ([]Arrival, error) {
+	var arrivals []Arrival
+	dec := json.NewDecoder(r)
+	for {
+		var a Arrival
+		err := dec.Decode(&a)
+		if err == io.EOF {
+			return arrivals, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("workload: %w", err)
+		}
+		arrivals = append(arrivals, a)
+	}
+}
+
+// WorkloadSource 按记录的间隔原样重放工作负载。
+func WorkloadSource(arrivals []Arrival) OrderSource {
+	next := 0
+	return SourceFunc(func(ctx context.Context) (Order, error) {
+		if next >= len(arrivals) {
+			return Order{}, io.EOF
+		}
+		a := arrivals[next]
+		next++
+		if err := sleep(ctx, a.Delay); err != nil {
+			return Order{}, err
+		}
+		return a.Order, nil
+	})
+}
+
+// sleep 可以被 ctx 打断的 time.Sleep。
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
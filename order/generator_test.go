@@ -0,0 +1,97 @@
+package order_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+func TestGeneratorDeterministic(t *testing.T) {
+	cfg := func(seed uint64) order.GeneratorConfig {
+		return order.GeneratorConfig{
+			Seed:        seed,
+			Catalog:     []string{"A", "B", "C", "D"},
+			Amount:      order.NormalAmount(200, 50, 10),
+			Arrival:     order.PoissonArrival(100),
+			ExpressRate: 0.2,
+		}
+	}
+
+	a := order.NewGenerator(cfg(42)).Workload(100)
+	b := order.NewGenerator(cfg(42)).Workload(100)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("same seed produced different workloads")
+	}
+	if c := order.NewGenerator(cfg(43)).Workload(100); reflect.DeepEqual(a, c) {
+		t.Fatal("different seeds produced the same workload")
+	}
+
+	var total time.Duration
+	for i, arr := range a {
+		if arr.Order.ID != i+1 {
+			t.Fatalf("order %d has ID %d", i, arr.Order.ID)
+		}
+		if n := len(arr.Order.Items); n < 1 || n > 3 {
+			t.Fatalf("order #%d has %d items", arr.Order.ID, n)
+		}
+		total += arr.Delay
+	}
+	// 每秒100个，平均间隔10ms。
+	if mean := total / 100; mean < 5*time.Millisecond || mean > 20*time.Millisecond {
+		t.Errorf("mean Poisson interval = %v, want about 10ms", mean)
+	}
+}
+
+func TestBurstyArrival(t *testing.T) {
+	g := order.NewGenerator(order.GeneratorConfig{
+		Count:   9,
+		Arrival: order.BurstyArrival(3, time.Millisecond, time.Second),
+	})
+
+	var idle []int
+	for i, a := range g.Workload(100) {
+		if a.Delay == time.Second {
+			idle = append(idle, i)
+		}
+	}
+	if !reflect.DeepEqual(idle, []int{3, 6}) {
+		t.Errorf("idle gaps before orders %v, want [3 6]", idle)
+	}
+}
+
+func TestWorkloadRecordAndReplay(t *testing.T) {
+	recorded := order.NewGenerator(order.GeneratorConfig{
+		Seed:    7,
+		Arrival: order.UniformArrival(0, time.Millisecond),
+	}).Workload(20)
+
+	var buf bytes.Buffer
+	if err := order.WriteWorkload(&buf, recorded); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := order.ReadWorkload(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorded, loaded) {
+		t.Fatal("workload changed after write/read")
+	}
+
+	src := order.WorkloadSource(loaded)
+	for i := range loaded {
+		o, err := src.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(o, recorded[i].Order) {
+			t.Fatalf("replayed order %d = %+v, want %+v", i, o, recorded[i].Order)
+		}
+	}
+	if _, err := src.Next(context.Background()); err == nil {
+		t.Fatal("replay did not end with an error")
+	}
+}
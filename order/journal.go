@@ -0,0 +1,171 @@
+package order
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Journal 订单的预写日志，一行一条 JSON 记录。
+// 订单进入队列之前记一条 enqueue，处理结束（成功或者最终失败）之后记一条 ack。
+// 进程挂掉重启后，有 enqueue 没有 ack 的订单会被重新处理，所以投递语义是至少一次，订单ID需要唯一。
+//
+// 写文件的方式和 other/gojingjin/57_IO 里的 directWriteByteSliceToFile 一样：
+// O_APPEND 打开，每次写完 Sync，保证记录真正落盘。
+type Journal struct {
+	mu      sync.Mutex
+	f       *os.File
+	pending []Order
+}
+
+type journalEntry struct {
+	Op    string `json:"op"` // "enqueue" 或 "ack"
+	Order *Order `json:"order,omitempty"`
+	ID    int    `json:"id"`
+}
+
+// OpenJournal 打开（或创建）日志文件，读出未确认的订单，并把日志压缩成只包含这些订单。
+func OpenJournal(path string) (*Journal, error) {
+	pending, err := readJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := rewriteJournal(path, pending); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	return &Journal{f: f, pending: pending}, nil
+}
+
+// Pending 返回打开日志时未确认的订单，按入队顺序排列。
+func (j *Journal) Pending() []Order {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Order(nil), j.pending...)
+}
+
+// Append 记录订单入队。
+func (j *Journal) Append(o Order) error {
+	return j.write(journalEntry{Op: "enqueue", Order: &o})
+}
+
+// Ack 记录订单处理结束。
+func (j *Journal) Ack(id int) error {
+	return j.write(journalEntry{Op: "ack", ID: id})
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+func (j *Journal) write(e journalEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(line); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return nil
+}
+
+func readJournal(path string) ([]Order, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	defer f.Close()
+
+	var ids []int // 订单ID第一次出现的顺序
+	pending := make(map[int]Order)
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var e journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// 最后一行可能是进程挂掉时只写了一半，忽略即可；中间的行坏了说明文件有问题。
+			if sc.Scan() {
+				return nil, fmt.Errorf("journal: %s:%d: %w", path, line, err)
+			}
+			break
+		}
+
+		switch e.Op {
+		case "enqueue":
+			if e.Order == nil {
+				continue
+			}
+			if _, ok := pending[e.Order.ID]; !ok {
+				ids = append(ids, e.Order.ID)
+			}
+			pending[e.Order.ID] = *e.Order
+		case "ack":
+			delete(pending, e.ID)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+
+	var orders []Order
+	for _, id := range ids {
+		if o, ok := pending[id]; ok {
+			orders = append(orders, o)
+			delete(pending, id) // 同一个ID被重复记录时只返回一次
+		}
+	}
+	return orders, nil
+}
+
+// rewriteJournal 先写临时文件再 rename，中途挂掉也不会丢掉原来的日志。
+func rewriteJournal(path string, orders []Order) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range orders {
+		if err := enc.Encode(journalEntry{Op: "enqueue", Order: &orders[i]}); err != nil {
+			f.Close()
+			return fmt.Errorf("journal: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package order_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+func TestJournalReplayAfterStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.wal")
+
+	j, err := order.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次运行：订单3、4、5的处理被 Stop 打断，模拟进程挂掉。
+	var once sync.Once
+	blocked := make(chan struct{})
+	handler := func(ctx context.Context, o order.Order) error {
+		if o.ID >= 3 {
+			once.Do(func() { close(blocked) })
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	p := order.NewProcessor(order.SliceSource(makeOrders(5)...), handler, 3, 5, order.WithJournal(j))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-blocked
+	for p.Metrics().Enqueued < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	for range 2 {
+		<-p.Results()
+	}
+	p.Stop()
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 再追加半行，模拟写到一半挂掉。
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"enq`)
+	f.Close()
+
+	// 第二次运行：只重新处理没确认的订单。
+	j, err = order.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pending []int
+	for _, o := range j.Pending() {
+		pending = append(pending, o.ID)
+	}
+	if len(pending) != 3 || pending[0] != 3 || pending[2] != 5 {
+		t.Fatalf("pending = %v, want [3 4 5]", pending)
+	}
+
+	p = order.NewProcessor(order.SliceSource(), func(context.Context, order.Order) error { return nil }, 2, 5, order.WithJournal(j))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var replayed []int
+	for r := range p.Results() {
+		replayed = append(replayed, r.Order.ID)
+	}
+	sort.Ints(replayed)
+	if len(replayed) != 3 || replayed[0] != 3 || replayed[2] != 5 {
+		t.Errorf("replayed = %v, want [3 4 5]", replayed)
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	// 第三次打开：全部确认过了。
+	j, err = order.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if n := len(j.Pending()); n != 0 {
+		t.Errorf("pending after replay = %d, want 0", n)
+	}
+}
+
+func TestJournalKeepsOriginalWhenDuplicateSkipped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.wal")
+	j, err := order.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 原订单一直处理不完，重复的那个被跳过之后进程挂掉。
+	handler := func(ctx context.Context, o order.Order) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	p := order.NewProcessor(order.SliceSource(order.Order{ID: 1}, order.Order{ID: 1}), handler, 2, 2,
+		order.WithJournal(j), order.WithDedup(order.NewDeduper(10, time.Minute)))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-p.Results(); !r.Duplicate {
+		t.Fatalf("first result = %+v, want the duplicate skipped", r)
+	}
+	p.Stop()
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = order.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if pending := j.Pending(); len(pending) != 1 || pending[0].ID != 1 {
+		t.Errorf("pending = %v, want order #1 kept for replay", pending)
+	}
+}
@@ -0,0 +1,228 @@
+package order
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets 直方图默认的桶边界（秒），和 Prometheus 客户端库的默认值一致。
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram 一个简单的累计直方图，单位为秒。
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64 // counts[i] 是落在 (buckets[i-1], buckets[i]] 的样本数，最后一个是 +Inf
+	sum     float64
+	count   uint64
+}
+
+func NewHistogram(buckets []float64) *Histogram {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &Histogram{buckets: b, counts: make([]uint64, len(b)+1)}
+}
+
+func (h *Histogram) Observe(d time.Duration) {
+	v := d.Seconds()
+	i := sort.SearchFloat64s(h.buckets, v) // 第一个 >= v 的桶
+
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// HistogramSnapshot 直方图快照。Counts 是累计值：Counts[i] 表示 <= Buckets[i] 的样本数。
+type HistogramSnapshot struct {
+	Buckets []float64
+	Counts  []uint64
+	Sum     float64
+	Count   uint64
+}
+
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := HistogramSnapshot{
+		Buckets: append([]float64(nil), h.buckets...),
+		Counts:  make([]uint64, len(h.buckets)),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+	var acc uint64
+	for i := range h.buckets {
+		acc += h.counts[i]
+		s.Counts[i] = acc
+	}
+	return s
+}
+
+// metrics Processor 内部的计数器。
+type metrics struct {
+	enqueued   atomic.Int64
+	processed  atomic.Int64 // 处理结束的订单数，包括失败的
+	failed     atomic.Int64
+	duplicates atomic.Int64 // 被 Deduper 跳过的重复订单数
+	consumers  atomic.Int64 // 还活着的消费者数
+
+	mu       sync.Mutex
+	inFlight map[int]int64 // 消费者编号 -> 正在处理的订单数
+
+	queueWait *Histogram
+	latency   *Histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		inFlight:  make(map[int]int64),
+		queueWait: NewHistogram(DefaultBuckets),
+		latency:   NewHistogram(DefaultBuckets),
+	}
+}
+
+func (m *metrics) addInFlight(consumer int, delta int64) {
+	m.mu.Lock()
+	m.inFlight[consumer] += delta
+	m.mu.Unlock()
+}
+
+// removeConsumer 消费者退出时删掉它的记录，否则缩容之后指标里会一直留着已经退出的消费者。
+func (m *metrics) removeConsumer(consumer int) {
+	m.mu.Lock()
+	delete(m.inFlight, consumer)
+	m.mu.Unlock()
+}
+
+// Snapshot 某一时刻的运行指标。
+type Snapshot struct {
+	Enqueued   int64
+	Processed  int64 // 处理结束的订单数，包括失败的
+	Failed     int64
+	Duplicates int64         // 被 Deduper 跳过的重复订单数
+	Consumers  int           // 还活着的消费者数
+	InFlight   map[int]int64 // 消费者编号 -> 正在处理的订单数
+
+	QueueLen int
+	QueueCap int
+
+	QueueWait HistogramSnapshot // 订单在队列中等待的时间
+	Latency   HistogramSnapshot // 订单处理耗时（包括重试）
+}
+
+// Metrics 返回当前运行指标的快照。
+func (p *Processor) Metrics() Snapshot {
+	m := p.metrics
+	s := Snapshot{
+		Enqueued:   m.enqueued.Load(),
+		Processed:  m.processed.Load(),
+		Failed:     m.failed.Load(),
+		Duplicates: m.duplicates.Load(),
+		Consumers:  int(m.consumers.Load()),
+		InFlight:   make(map[int]int64),
+		QueueLen:   p.QueueLen(),
+		QueueCap:   p.QueueCap(),
+		QueueWait:  m.queueWait.Snapshot(),
+		Latency:    m.latency.Snapshot(),
+	}
+
+	m.mu.Lock()
+	for id, n := range m.inFlight {
+		s.InFlight[id] = n
+	}
+	m.mu.Unlock()
+	return s
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出运行指标，可以挂在本地 HTTP 服务的 /metrics 上。
+func (p *Processor) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w, p.Metrics())
+	})
+}
+
+// WritePrometheus 把快照写成 Prometheus 文本格式。
+func WritePrometheus(w io.Writer, s Snapshot) error {
+	pw := &promWriter{w: w}
+
+	pw.metric("order_enqueued_total", "counter", "Orders put into the queue.")
+	pw.sample("order_enqueued_total", "", float64(s.Enqueued))
+	pw.metric("order_processed_total", "counter", "Orders that finished processing, including failures.")
+	pw.sample("order_processed_total", "", float64(s.Processed))
+	pw.metric("order_failed_total", "counter", "Orders that failed after all attempts.")
+	pw.sample("order_failed_total", "", float64(s.Failed))
+	pw.metric("order_duplicates_skipped_total", "counter", "Duplicate orders skipped by the deduper.")
+	pw.sample("order_duplicates_skipped_total", "", float64(s.Duplicates))
+
+	pw.metric("order_consumers", "gauge", "Running consumer goroutines.")
+	pw.sample("order_consumers", "", float64(s.Consumers))
+	pw.metric("order_queue_length", "gauge", "Orders waiting in the queue.")
+	pw.sample("order_queue_length", "", float64(s.QueueLen))
+	pw.metric("order_queue_capacity", "gauge", "Queue capacity.")
+	pw.sample("order_queue_capacity", "", float64(s.QueueCap))
+
+	pw.metric("order_in_flight", "gauge", "Orders being processed, per consumer.")
+	ids := make([]int, 0, len(s.InFlight))
+	for id := range s.InFlight {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		pw.sample("order_in_flight", `consumer="`+strconv.Itoa(id)+`"`, float64(s.InFlight[id]))
+	}
+
+	pw.histogram("order_queue_wait_seconds", "Time orders spent waiting in the queue.", s.QueueWait)
+	pw.histogram("order_processing_seconds", "Time spent processing orders, including retries.", s.Latency)
+	return pw.err
+}
+
+// promWriter 记住第一个写错误，后面的写入都跳过，省得每一行都判断 err。
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (pw *promWriter) printf(format string, args ...any) {
+	if pw.err != nil {
+		return
+	}
+	_, pw.err = fmt.Fprintf(pw.w, format, args...)
+}
+
+func (pw *promWriter) metric(name, typ, help string) {
+	pw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (pw *promWriter) sample(name, labels string, v float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	pw.printf("%s %s\n", name, formatFloat(v))
+}
+
+func (pw *promWriter) histogram(name, help string, h HistogramSnapshot) {
+	pw.metric(name, "histogram", help)
+	for i, b := range h.Buckets {
+		pw.sample(name+"_bucket", `le="`+formatFloat(b)+`"`, float64(h.Counts[i]))
+	}
+	pw.sample(name+"_bucket", `le="+Inf"`, float64(h.Count))
+	pw.sample(name+"_sum", "", h.Sum)
+	pw.sample(name+"_count", "", float64(h.Count))
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,79 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+func TestHistogramSnapshot(t *testing.T) {
+	h := order.NewHistogram([]float64{0.1, 1})
+	h.Observe(50 * time.Millisecond)
+	h.Observe(100 * time.Millisecond)
+	h.Observe(500 * time.Millisecond)
+	h.Observe(3 * time.Second)
+
+	s := h.Snapshot()
+	if s.Count != 4 || s.Counts[0] != 2 || s.Counts[1] != 3 {
+		t.Errorf("snapshot = %+v, want count 4 and cumulative counts [2 3]", s)
+	}
+	if s.Sum < 3.64 || s.Sum > 3.66 {
+		t.Errorf("sum = %v, want 3.65", s.Sum)
+	}
+}
+
+func TestProcessorMetrics(t *testing.T) {
+	handler := func(ctx context.Context, o order.Order) error {
+		time.Sleep(2 * time.Millisecond)
+		if o.ID%4 == 0 {
+			return errors.New("pay failed")
+		}
+		return nil
+	}
+
+	p := order.NewProcessor(order.SliceSource(makeOrders(20)...), handler, 3, 5)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for range p.Results() {
+	}
+
+	s := p.Metrics()
+	if s.Enqueued != 20 || s.Processed != 20 || s.Failed != 5 {
+		t.Errorf("enqueued=%d processed=%d failed=%d, want 20/20/5", s.Enqueued, s.Processed, s.Failed)
+	}
+	if s.Consumers != 0 {
+		t.Errorf("consumers = %d after finish, want 0", s.Consumers)
+	}
+	if len(s.InFlight) != 0 {
+		t.Errorf("InFlight = %v after finish, want exited consumers removed", s.InFlight)
+	}
+	if s.Latency.Count != 20 || s.QueueWait.Count != 20 {
+		t.Errorf("latency count=%d queue wait count=%d, want 20", s.Latency.Count, s.QueueWait.Count)
+	}
+
+	rec := httptest.NewRecorder()
+	p.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, line := range []string{
+		"# TYPE order_enqueued_total counter",
+		"order_processed_total 20",
+		"order_failed_total 5",
+		"# TYPE order_in_flight gauge",
+		`order_processing_seconds_bucket{le="+Inf"} 20`,
+		"order_queue_wait_seconds_count 20",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("metrics output missing %q", line)
+		}
+	}
+	if strings.Contains(string(body), "order_in_flight{") {
+		t.Errorf("metrics output still reports exited consumers:\n%s", body)
+	}
+}
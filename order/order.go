@@ -0,0 +1,71 @@
+// Package order 把 pc_model.go 里的生产者消费者模型整理成可复用的订单处理包。
+//
+// 生产者从 OrderSource 取订单放进队列，一组消费者从队列里取订单交给 Handler 处理，
+// 每个订单的处理结果通过 Results 通道交给调用方。
+package order
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// 订单结构体
+type Order struct {
+	ID      int
+	Amount  float64
+	Items   []string
+	Express bool // 加急订单，打开优先级通道时走高优先级
+
+	Attempts int   // 已经处理过几次
+	LastErr  error `json:"-"` // 最近一次处理失败的原因
+
+	EnqueuedAt time.Time // 进入队列的时间，由 Processor 填写
+}
+
+// OrderSource 订单来源。Next 返回 io.EOF 表示订单已经全部产出。
+// Processor 只会在一个生产者goroutine里调用 Next，实现不需要考虑并发。
+type OrderSource interface {
+	Next(ctx context.Context) (Order, error)
+}
+
+// SourceFunc 让普通函数也能当 OrderSource 用，类似 http.HandlerFunc。
+type SourceFunc func(ctx context.Context) (Order, error)
+
+func (f SourceFunc) Next(ctx context.Context) (Order, error) {
+	return f(ctx)
+}
+
+// SliceSource 按顺序产出给定的订单，产完返回 io.EOF。
+func SliceSource(orders ...Order) OrderSource {
+	return &sliceSource{orders: orders}
+}
+
+type sliceSource struct {
+	orders []Order
+	next   int
+}
+
+func (s *sliceSource) Next(ctx context.Context) (Order, error) {
+	if err := ctx.Err(); err != nil {
+		return Order{}, err
+	}
+	if s.next >= len(s.orders) {
+		return Order{}, io.EOF
+	}
+	o := s.orders[s.next]
+	s.next++
+	return o, nil
+}
+
+// Handler 处理单个订单。ctx 在 Processor 停止时会被取消。
+type Handler func(ctx context.Context, o Order) error
+
+// Result 一个订单的处理结果。
+type Result struct {
+	Order     Order
+	Consumer  int // 处理该订单的消费者编号，从1开始
+	Err       error
+	Duration  time.Duration
+	Duplicate bool // 重复订单，被 Deduper 跳过，没有交给 Handler
+}
@@ -0,0 +1,123 @@
+package order
+
+import (
+	"context"
+	"time"
+)
+
+// PriorityPolicy 优先级通道配置。
+// 加急订单或金额不低于 AmountThreshold 的订单走高优先级通道，消费者总是先取高优先级通道。
+// 为了不让普通订单饿死，消费者连续取了 LowEvery 个高优先级订单之后，只要普通通道里有订单，就先取一个普通订单。
+type PriorityPolicy struct {
+	AmountThreshold float64 // 0表示不按金额区分
+	LowEvery        int     // 小于1时按4处理
+}
+
+// IsHigh 判断订单是否走高优先级通道。
+func (pp PriorityPolicy) IsHigh(o Order) bool {
+	return o.Express || (pp.AmountThreshold > 0 && o.Amount >= pp.AmountThreshold)
+}
+
+func (pp PriorityPolicy) lowEvery() int {
+	if pp.LowEvery < 1 {
+		return 4
+	}
+	return pp.LowEvery
+}
+
+// WithPriority 打开优先级通道。高优先级通道和普通通道的容量都是 queueSize。
+func WithPriority(pp PriorityPolicy) Option {
+	return func(p *Processor) {
+		p.priority = &pp
+	}
+}
+
+// lanes 是一个消费者眼中的两条通道。某条通道关闭后就置为nil，nil channel 在 select 中永远不会被选中。
+type lanes struct {
+	high, low <-chan Order
+	quit      <-chan struct{} // 自动伸缩的缩容信号
+	streak    int             // 连续取了多少个高优先级订单
+	lowEvery  int
+	stopped   bool // 通道都关闭了、ctx 被取消或者收到了缩容信号，消费者应当退出
+}
+
+func (p *Processor) newLanes() *lanes {
+	l := &lanes{high: p.high, low: p.queue, quit: p.shrink, lowEvery: 1}
+	if p.priority != nil {
+		l.lowEvery = p.priority.lowEvery()
+	}
+	return l
+}
+
+// next 取下一个订单。两条通道都关闭、ctx 被取消或者收到缩容信号时返回 false，并设置 stopped；
+// timeout 触发时也返回 false，但不设置 stopped。timeout 为nil表示一直等。
+func (l *lanes) next(ctx context.Context, timeout <-chan time.Time) (Order, bool) {
+	for l.high != nil || l.low != nil {
+		if ctx.Err() != nil {
+			l.stopped = true
+			return Order{}, false
+		}
+
+		if l.high != nil && l.streak >= l.lowEvery && l.low != nil {
+			// 高优先级连续取够了，普通通道有货就让它一次。
+			select {
+			case o, ok := <-l.low:
+				if l.take(o, ok, false) {
+					return o, true
+				}
+				continue
+			default:
+			}
+		}
+
+		if l.high != nil {
+			select {
+			case o, ok := <-l.high:
+				if l.take(o, ok, true) {
+					return o, true
+				}
+				continue
+			default:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			l.stopped = true
+			return Order{}, false
+		case <-l.quit:
+			l.stopped = true
+			return Order{}, false
+		case <-timeout:
+			return Order{}, false
+		case o, ok := <-l.high:
+			if l.take(o, ok, true) {
+				return o, true
+			}
+		case o, ok := <-l.low:
+			if l.take(o, ok, false) {
+				return o, true
+			}
+		}
+	}
+	l.stopped = true
+	return Order{}, false
+}
+
+func (l *lanes) take(o Order, ok bool, high bool) bool {
+	if !ok {
+		if high {
+			l.high = nil
+		} else {
+			l.low = nil
+		}
+		return false
+	}
+
+	if high {
+		l.streak++
+	} else {
+		l.streak = 0
+	}
+	return true
+}
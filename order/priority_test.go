@@ -0,0 +1,63 @@
+package order_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+func TestProcessorPriorityLanes(t *testing.T) {
+	// 订单1是普通订单，用来把唯一的消费者卡住，等其余订单都进了队列再放行。
+	// 2-7是普通订单，8-13是大额订单，14是加急订单。
+	orders := []order.Order{{ID: 1, Amount: 10}}
+	for id := 2; id <= 7; id++ {
+		orders = append(orders, order.Order{ID: id, Amount: 10})
+	}
+	for id := 8; id <= 13; id++ {
+		orders = append(orders, order.Order{ID: id, Amount: 1000})
+	}
+	orders = append(orders, order.Order{ID: 14, Amount: 10, Express: true})
+
+	firstTaken := make(chan struct{})
+	next := 0
+	src := order.SourceFunc(func(ctx context.Context) (order.Order, error) {
+		if next == 1 {
+			<-firstTaken
+		}
+		if next >= len(orders) {
+			return order.Order{}, io.EOF
+		}
+		next++
+		return orders[next-1], nil
+	})
+
+	var p *order.Processor
+	var handled []int
+	handler := func(ctx context.Context, o order.Order) error {
+		if o.ID == 1 {
+			close(firstTaken)
+			for p.QueueLen() < len(orders)-1 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		handled = append(handled, o.ID) // 只有一个消费者，不需要加锁
+		return nil
+	}
+
+	p = order.NewProcessor(src, handler, 1, 20,
+		order.WithPriority(order.PriorityPolicy{AmountThreshold: 500, LowEvery: 2}))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for range p.Results() {
+	}
+
+	want := []int{1, 8, 9, 2, 10, 11, 3, 12, 13, 4, 14, 5, 6, 7}
+	if !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled order = %v\nwant %v", handled, want)
+	}
+}
@@ -0,0 +1,326 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var ErrAlreadyStarted = errors.New("order: processor already started")
+
+// Processor 一个生产者 + workers 个消费者，中间用容量为 queueSize 的 channel 连接。
+type Processor struct {
+	src       OrderSource
+	handler   Handler
+	workers   int
+	queueSize int
+
+	retry      RetryPolicy
+	deadLetter chan<- Order
+	priority   *PriorityPolicy
+	autoscale  *AutoscalePolicy
+	journal    *Journal
+	dedup      *Deduper
+	batch      *batching
+
+	queue   chan Order // 普通通道；没有打开优先级时所有订单都走这里
+	high    chan Order // 高优先级通道，没有打开优先级时为nil
+	results chan Result
+	metrics *metrics
+
+	produced chan struct{} // 生产者退出时关闭
+	shrink   chan struct{} // 自动伸缩时用来通知一个空闲消费者退出，没打开伸缩时为nil
+
+	mu         sync.Mutex
+	started    bool
+	cancel     context.CancelFunc // 取消所有goroutine，包括正在执行的 Handler
+	stopIntake context.CancelFunc // 只停止生产者
+	abandoned  []Order            // 被打断、没有处理完的订单
+	srcErr     error
+	lastID     int            // 最近一个消费者的编号
+	wg         sync.WaitGroup // 生产者、所有消费者和伸缩goroutine
+}
+
+// Option 用来配置 Processor 的可选功能。
+type Option func(*Processor)
+
+// WithRetry 设置失败订单的重试策略。默认不重试。
+func WithRetry(rp RetryPolicy) Option {
+	return func(p *Processor) {
+		p.retry = rp
+	}
+}
+
+// WithDeadLetter 重试耗尽（或错误不可重试）的订单会被发送到 ch，订单的 Attempts 和 LastErr 记录了失败经过。
+// 调用方需要及时读取 ch，否则消费者会阻塞。这些订单仍然会以失败结果出现在 Results 里。
+func WithDeadLetter(ch chan<- Order) Option {
+	return func(p *Processor) {
+		p.deadLetter = ch
+	}
+}
+
+// WithJournal 用预写日志记录订单。Start 时会先重新处理日志里未确认的订单，再从订单源取新订单。
+// 被 Stop 打断、没有处理完的订单不会确认，下次启动时重新处理。
+func WithJournal(j *Journal) Option {
+	return func(p *Processor) {
+		p.journal = j
+	}
+}
+
+// NewProcessor 创建一个订单处理器。workers 小于1时按1处理，queueSize 小于0时按0（无缓冲）处理。
+func NewProcessor(src OrderSource, handler Handler, workers, queueSize int, opts ...Option) *Processor {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Processor{
+		src:       src,
+		handler:   handler,
+		workers:   workers,
+		queueSize: queueSize,
+		queue:     make(chan Order, queueSize),
+		results:   make(chan Result, queueSize),
+		metrics:   newMetrics(),
+		produced:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.priority != nil {
+		p.high = make(chan Order, queueSize)
+	}
+	return p
+}
+
+// Start 启动生产者和消费者后立即返回。
+// 订单源产完之后，消费者把队列里剩下的订单处理完就退出，随后 Results 通道被关闭。
+func (p *Processor) Start(ctx context.Context) error {
+	p.mu.Lock()
+	if p.started {
+		p.mu.Unlock()
+		return ErrAlreadyStarted
+	}
+	p.started = true
+	ctx, p.cancel = context.WithCancel(ctx)
+	intake, stopIntake := context.WithCancel(ctx)
+	p.stopIntake = stopIntake
+	p.wg.Add(1) // Start 自己也占一个计数，保证启动过程中计数不会归零
+	p.mu.Unlock()
+	defer p.wg.Done()
+
+	p.wg.Add(1)
+	go p.produce(ctx, intake)
+	for range p.workers {
+		p.spawnConsumer(ctx)
+	}
+	if p.autoscale != nil {
+		p.wg.Add(1)
+		go p.scale(ctx)
+	}
+
+	go func() {
+		p.wg.Wait()
+		close(p.results) // 所有消费者都退出了，不会再有结果写入。
+	}()
+	return nil
+}
+
+// Stop 相当于 HardStop，但不关心哪些订单没处理完。可以重复调用。
+func (p *Processor) Stop() {
+	p.HardStop()
+}
+
+// Results 返回处理结果通道。所有消费者退出后该通道被关闭。
+// 调用方需要及时读取，否则消费者会阻塞在写结果上。
+func (p *Processor) Results() <-chan Result {
+	return p.results
+}
+
+// Err 返回订单源产生的非 io.EOF 错误，或者写日志时遇到的第一个错误。
+func (p *Processor) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.srcErr
+}
+
+func (p *Processor) setErr(err error) {
+	p.mu.Lock()
+	if p.srcErr == nil {
+		p.srcErr = err
+	}
+	p.mu.Unlock()
+}
+
+// QueueLen 当前队列中等待处理的订单数（包括高优先级通道）。
+func (p *Processor) QueueLen() int {
+	return len(p.queue) + len(p.high)
+}
+
+// QueueCap 队列容量（包括高优先级通道）。
+func (p *Processor) QueueCap() int {
+	return cap(p.queue) + cap(p.high)
+}
+
+// produce 生产者。intake 被取消时不再取新订单，但手上这一个还会放进队列；ctx 被取消时立即退出。
+func (p *Processor) produce(ctx, intake context.Context) {
+	defer p.wg.Done()
+	defer func() { // 关闭通道以通知消费者
+		close(p.produced)
+		close(p.queue)
+		if p.high != nil {
+			close(p.high)
+		}
+	}()
+
+	// 先重新处理日志里上次没有确认的订单，它们已经在日志里了，不用再记一遍。
+	var replay []Order
+	if p.journal != nil {
+		replay = p.journal.Pending()
+	}
+
+	for {
+		if intake.Err() != nil {
+			p.abandon(replay...) // 还没重放的订单留在日志里，下次启动再处理
+			return
+		}
+
+		var o Order
+		if len(replay) > 0 {
+			o, replay = replay[0], replay[1:]
+		} else {
+			var err error
+			o, err = p.src.Next(intake)
+			if err != nil {
+				if !errors.Is(err, io.EOF) && intake.Err() == nil {
+					p.setErr(err)
+				}
+				return
+			}
+
+			if p.journal != nil {
+				if err := p.journal.Append(o); err != nil {
+					p.setErr(err)
+					return
+				}
+			}
+		}
+
+		lane := p.queue
+		if p.priority != nil && p.priority.IsHigh(o) {
+			lane = p.high
+		}
+
+		o.EnqueuedAt = time.Now()
+		select {
+		case lane <- o: // 阻塞直到队列有空位
+			p.metrics.enqueued.Add(1)
+		case <-ctx.Done():
+			p.abandon(o)
+			p.abandon(replay...)
+			return
+		}
+	}
+}
+
+func (p *Processor) consume(ctx context.Context, id int) {
+	defer p.wg.Done()
+	defer p.metrics.consumers.Add(-1) // 加1在 spawnConsumer 里
+	defer p.metrics.removeConsumer(id)
+	p.metrics.addInFlight(id, 0) // 还没接单的消费者也要出现在指标里
+
+	// 这里不能直接 for range p.queue：Stop 之后应当立刻退出，而不是把队列剩下的订单处理完。
+	lanes := p.newLanes()
+	if p.batch != nil {
+		p.consumeBatches(ctx, id, lanes)
+		return
+	}
+
+	for {
+		o, ok := lanes.next(ctx, nil)
+		if !ok {
+			return
+		}
+
+		keep, alive := p.admit(ctx, id, o)
+		if !alive {
+			return
+		}
+		if !keep {
+			continue
+		}
+
+		start := time.Now()
+		p.metrics.addInFlight(id, 1)
+		o = p.handle(ctx, o)
+		p.metrics.addInFlight(id, -1)
+
+		if ctx.Err() != nil {
+			p.abandonClaimed(o) // Handler 被强制停止打断了
+			return
+		}
+		if !p.complete(ctx, id, o, time.Since(start)) {
+			return
+		}
+	}
+}
+
+// admit 订单刚从队列里取出来时调用：记录排队时间，并跳过重复订单。
+// keep 表示订单需要处理；alive 为 false 表示 ctx 已取消，消费者应当退出。
+func (p *Processor) admit(ctx context.Context, id int, o Order) (keep, alive bool) {
+	p.metrics.queueWait.Observe(time.Since(o.EnqueuedAt))
+
+	if p.dedup != nil && !p.dedup.Claim(o.ID) {
+		p.metrics.duplicates.Add(1)
+		return false, p.finish(ctx, Result{Order: o, Consumer: id, Duplicate: true})
+	}
+	return true, true
+}
+
+// complete 订单处理结束（Attempts、LastErr 已更新）之后调用：更新指标、去重记录和死信，再交给 finish。
+func (p *Processor) complete(ctx context.Context, id int, o Order, d time.Duration) bool {
+	if p.dedup != nil {
+		p.dedup.Release(o.ID, o.LastErr == nil)
+	}
+	p.metrics.latency.Observe(d)
+	p.metrics.processed.Add(1)
+	if o.LastErr != nil {
+		p.metrics.failed.Add(1)
+	}
+
+	if o.LastErr != nil && p.deadLetter != nil && ctx.Err() == nil {
+		select {
+		case p.deadLetter <- o:
+		case <-ctx.Done():
+			p.abandon(o)
+			return false
+		}
+	}
+
+	return p.finish(ctx, Result{Order: o, Consumer: id, Err: o.LastErr, Duration: d})
+}
+
+// finish 确认订单并把结果交给调用方。ctx 被取消时返回 false，消费者应当退出。
+// 重复订单不确认：原订单可能还在处理中，确认会把它的日志记录也删掉，进程这时挂掉原订单就丢了。
+func (p *Processor) finish(ctx context.Context, r Result) bool {
+	if ctx.Err() != nil {
+		p.abandon(r.Order) // 被 Stop 打断，订单不确认，下次启动时重新处理
+		return false
+	}
+	if p.journal != nil && !r.Duplicate {
+		if err := p.journal.Ack(r.Order.ID); err != nil {
+			p.setErr(err)
+		}
+	}
+
+	select {
+	case p.results <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
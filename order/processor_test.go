@@ -0,0 +1,127 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"CInG/order"
+)
+
+func makeOrders(n int) []order.Order {
+	orders := make([]order.Order, n)
+	for i := range orders {
+		orders[i] = order.Order{ID: i + 1, Amount: float64(100 + i)}
+	}
+	return orders
+}
+
+func TestProcessorHandlesAllOrders(t *testing.T) {
+	var handled atomic.Int32
+	errPay := errors.New("pay failed")
+	handler := func(ctx context.Context, o order.Order) error {
+		handled.Add(1)
+		if o.ID%5 == 0 {
+			return errPay
+		}
+		return nil
+	}
+
+	p := order.NewProcessor(order.SliceSource(makeOrders(50)...), handler, 4, 10)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(context.Background()); !errors.Is(err, order.ErrAlreadyStarted) {
+		t.Fatalf("second Start: got %v, want ErrAlreadyStarted", err)
+	}
+
+	seen := make(map[int]bool)
+	failed := 0
+	for r := range p.Results() {
+		if seen[r.Order.ID] {
+			t.Fatalf("order #%d reported twice", r.Order.ID)
+		}
+		seen[r.Order.ID] = true
+		if r.Consumer < 1 || r.Consumer > 4 {
+			t.Errorf("order #%d: consumer %d out of range", r.Order.ID, r.Consumer)
+		}
+		if r.Err != nil {
+			failed++
+		}
+	}
+
+	if len(seen) != 50 || handled.Load() != 50 {
+		t.Fatalf("got %d results, %d handled; want 50", len(seen), handled.Load())
+	}
+	if failed != 10 {
+		t.Errorf("got %d failed orders, want 10", failed)
+	}
+	p.Stop()
+}
+
+func TestProcessorStop(t *testing.T) {
+	// 订单源永远产不完，只能靠 Stop 结束。
+	src := order.SourceFunc(func(ctx context.Context) (order.Order, error) {
+		return order.Order{ID: 1}, nil
+	})
+	handler := func(ctx context.Context, o order.Order) error {
+		select {
+		case <-time.After(10 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	p := order.NewProcessor(src, handler, 3, 5)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-p.Results()
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		p.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return")
+	}
+
+	for range p.Results() {
+	}
+}
+
+func TestProcessorSourceError(t *testing.T) {
+	errBroken := errors.New("source broken")
+	calls := 0
+	src := order.SourceFunc(func(ctx context.Context) (order.Order, error) {
+		calls++
+		if calls > 3 {
+			return order.Order{}, errBroken
+		}
+		return order.Order{ID: calls}, nil
+	})
+
+	p := order.NewProcessor(src, func(context.Context, order.Order) error { return nil }, 2, 0)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for range p.Results() {
+		n++
+	}
+	if n != 3 {
+		t.Errorf("got %d results, want 3", n)
+	}
+	if !errors.Is(p.Err(), errBroken) {
+		t.Errorf("Err() = %v, want %v", p.Err(), errBroken)
+	}
+}
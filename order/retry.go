@@ -0,0 +1,120 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"time"
+
+	"CInG/join"
+)
+
+// PaymentError 支付失败。Temporary 为 true 表示可以重试（比如网关超时），
+// 为 false 表示重试也没用（比如余额不足）。
+type PaymentError struct {
+	OrderID   int
+	Reason    string
+	Temporary bool
+}
+
+func (e *PaymentError) Error() string {
+	return fmt.Sprintf("order #%d: payment failed: %s", e.OrderID, e.Reason)
+}
+
+// RetryPolicy 失败订单的重试策略：指数退避 + 随机抖动。
+// 第n次重试前等待 BaseDelay * Multiplier^(n-1)，不超过 MaxDelay，
+// 再在 [d*(1-Jitter), d*(1+Jitter)] 范围内随机取值，避免大量订单同时重试。
+type RetryPolicy struct {
+	MaxAttempts int // 最多处理几次（包括第一次），小于等于1表示不重试
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration // 0表示不设上限
+	Multiplier  float64       // 小于1时按2处理
+	Jitter      float64       // 0-1
+
+	// Retryable 判断错误是否值得重试，为nil时使用 DefaultRetryable。
+	Retryable func(error) bool
+}
+
+// DefaultRetryable 除了 ctx 被取消、Handler panic、BatchHandler 返回的错误个数不对（ErrBatchResult）
+// 和 Temporary 为 false 的 PaymentError，其余错误都重试。
+func DefaultRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrBatchResult) {
+		return false // 不知道哪些订单其实已经扣过款了，重试可能重复扣款
+	}
+	var panicErr *join.PanicError
+	if errors.As(err, &panicErr) {
+		return false // 同一个订单再处理一次多半还会 panic
+	}
+	var pe *PaymentError
+	if errors.As(err, &pe) {
+		return pe.Temporary
+	}
+	return true
+}
+
+// Backoff 返回第 retry 次重试（从1开始）之前需要等待的时间。
+func (rp RetryPolicy) Backoff(retry int) time.Duration {
+	if retry < 1 {
+		return 0
+	}
+	mult := rp.Multiplier
+	if mult < 1 {
+		mult = 2
+	}
+
+	d := float64(rp.BaseDelay)
+	for i := 1; i < retry; i++ {
+		d *= mult
+		if rp.MaxDelay > 0 && d >= float64(rp.MaxDelay) {
+			break
+		}
+	}
+	if rp.MaxDelay > 0 && d > float64(rp.MaxDelay) {
+		d = float64(rp.MaxDelay)
+	}
+
+	if j := min(max(rp.Jitter, 0), 1); j > 0 {
+		d *= 1 - j + 2*j*rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+func (rp RetryPolicy) retryable(err error) bool {
+	if rp.Retryable != nil {
+		return rp.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// handle 按重试策略处理一个订单，返回最终的订单状态（Attempts、LastErr 已更新）。
+func (p *Processor) handle(ctx context.Context, o Order) Order {
+	for {
+		o.Attempts++
+		o.LastErr = p.callHandler(ctx, o)
+		if o.LastErr == nil {
+			return o
+		}
+		if o.Attempts >= p.retry.MaxAttempts || !p.retry.retryable(o.LastErr) {
+			return o
+		}
+
+		if sleep(ctx, p.retry.Backoff(o.Attempts)) != nil {
+			return o
+		}
+	}
+}
+
+// callHandler 调用 Handler，把 panic 恢复成 *join.PanicError：一个坏订单只会让它自己失败，不会让整个进程崩溃。
+func (p *Processor) callHandler(ctx context.Context, o Order) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &join.PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return p.handler(ctx, o)
+}
@@ -0,0 +1,132 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"CInG/join"
+	"CInG/order"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	rp := order.RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+	want := []time.Duration{0, 10, 20, 40, 50, 50}
+	for retry, w := range want {
+		if got := rp.Backoff(retry); got != w*time.Millisecond {
+			t.Errorf("Backoff(%d) = %v, want %v", retry, got, w*time.Millisecond)
+		}
+	}
+
+	rp.Jitter = 0.5
+	for range 100 {
+		if d := rp.Backoff(2); d < 10*time.Millisecond || d > 30*time.Millisecond {
+			t.Fatalf("Backoff(2) with jitter = %v, want within [10ms, 30ms]", d)
+		}
+	}
+}
+
+func TestProcessorRetryAndDeadLetter(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[int]int)
+
+	handler := func(ctx context.Context, o order.Order) error {
+		mu.Lock()
+		calls[o.ID]++
+		n := calls[o.ID]
+		mu.Unlock()
+
+		switch o.ID {
+		case 1: // 第三次成功
+			if n < 3 {
+				return &order.PaymentError{OrderID: o.ID, Reason: "gateway timeout", Temporary: true}
+			}
+			return nil
+		case 2: // 一直失败
+			return &order.PaymentError{OrderID: o.ID, Reason: "gateway timeout", Temporary: true}
+		case 3: // 不可重试
+			return &order.PaymentError{OrderID: o.ID, Reason: "insufficient funds"}
+		}
+		return nil
+	}
+
+	dead := make(chan order.Order, 10)
+	p := order.NewProcessor(order.SliceSource(makeOrders(4)...), handler, 2, 4,
+		order.WithRetry(order.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond}),
+		order.WithDeadLetter(dead))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(map[int]order.Result)
+	for r := range p.Results() {
+		results[r.Order.ID] = r
+	}
+	close(dead)
+
+	if r := results[1]; r.Err != nil || r.Order.Attempts != 3 {
+		t.Errorf("order #1: err=%v attempts=%d, want success after 3 attempts", r.Err, r.Order.Attempts)
+	}
+	if r := results[2]; r.Err == nil || r.Order.Attempts != 4 {
+		t.Errorf("order #2: err=%v attempts=%d, want failure after 4 attempts", r.Err, r.Order.Attempts)
+	}
+	if r := results[3]; r.Order.Attempts != 1 {
+		t.Errorf("order #3: attempts=%d, want 1", r.Order.Attempts)
+	}
+
+	var deadIDs []int
+	for o := range dead {
+		var pe *order.PaymentError
+		if !errors.As(o.LastErr, &pe) || pe.OrderID != o.ID {
+			t.Errorf("dead letter #%d: LastErr = %v, want *PaymentError", o.ID, o.LastErr)
+		}
+		deadIDs = append(deadIDs, o.ID)
+	}
+	if len(deadIDs) != 2 {
+		t.Errorf("dead letters = %v, want orders 2 and 3", deadIDs)
+	}
+}
+
+func TestProcessorHandlerPanic(t *testing.T) {
+	handler := func(ctx context.Context, o order.Order) error {
+		if o.ID == 2 {
+			var items map[string]int
+			items["boom"]++ // 坏订单：写nil map
+		}
+		return nil
+	}
+
+	dead := make(chan order.Order, 10)
+	p := order.NewProcessor(order.SliceSource(makeOrders(4)...), handler, 2, 4,
+		order.WithRetry(order.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+		order.WithDeadLetter(dead))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ok := 0
+	for r := range p.Results() {
+		if r.Err == nil {
+			ok++
+			continue
+		}
+		var pe *join.PanicError
+		if r.Order.ID != 2 || !errors.As(r.Err, &pe) {
+			t.Errorf("order #%d: err = %v, want only order #2 to fail with a PanicError", r.Order.ID, r.Err)
+		}
+		if r.Order.Attempts != 1 {
+			t.Errorf("panicking order attempted %d times, want 1: panics are not retried", r.Order.Attempts)
+		}
+	}
+	if ok != 3 {
+		t.Errorf("%d orders succeeded, want 3", ok)
+	}
+
+	close(dead)
+	if o, found := <-dead; !found || o.ID != 2 {
+		t.Errorf("dead letter = %+v, want order #2", o)
+	}
+}
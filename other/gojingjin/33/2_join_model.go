@@ -7,6 +7,9 @@ import (
 	"time"
 )
 
+// 下面的 Spawn1、Spawn2、spawnGroup 用 ...interface{} 传参数，结果要靠类型断言取出来；
+// 带类型的泛型版本（Spawn、SpawnGroup、Future）在 CInG/join 包里，goroutine 里的 panic 也会变成错误返回。
+
 // 1.等待1个goroutine结束
 func worker(args ...interface{}) {
 	if len(args) == 0 {
@@ -24,7 +27,7 @@ func worker(args ...interface{}) {
 func Spawn1(f func(args ...interface{}), args ...interface{}) chan struct{} {
 	c := make(chan struct{})
 	go func() {
-		f(args)
+		f(args...)
 		c <- struct{}{}
 	}()
 
@@ -84,7 +87,7 @@ func spawnGroup(n int, f func(args ...interface{}), args ...interface{}) chan st
 	for i := range n {
 		wg.Add(1)
 		go func() {
-			f(args, i+1)
+			f(append(args, i+1)...)
 			println(fmt.Sprintf("worker-%d", i), "done")
 
 			wg.Done()
@@ -7,6 +7,8 @@ import (
 	"time"
 )
 
+// 下面的 GracefullyShutdowner、ConcurrentShutdown、SequentialShutdown 整理成了可以直接导入的 CInG/lifecycle 包，
+// 按依赖关系退出的 ShutdownGraph、收到 SIGTERM 时调用这些退出函数的 App 也在那里。
 type GracefullyShutdowner interface {
 	Shutdown(waitTimeout time.Duration) error
 }
@@ -40,6 +40,8 @@ func first1(servers ...*httptest.Server) (result, error) {
 	return <-c, nil
 }
 
+// fakeWeatherServer1、fakeWeatherServer2、fakeWeatherServer 是同一个东西的三份拷贝；
+// 可以配置延迟分布、错误率、慢响应体、连接重置和脚本化响应的版本见 CInG/fakeserver 包。
 func fakeWeatherServer1(name string, interval int) *httptest.Server {
 	// 相当于开了一个http服务器。可以通过某些方法获取该服务器的url等以供访问。
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -112,8 +114,12 @@ func main() {
 }
 
 // 第三版：
+// 取第一个成功的结果、取消其余请求，就是 CInG/join 包里的 Any：每个服务器用 join.Spawn 发一个请求，
+// 再用 join.Any(...).AwaitTimeout(500*time.Millisecond) 等结果。
+// 先只请求主服务器、慢了才请求备用服务器的版本见 CInG/hedge 包的 HedgedClient，
+// 要 K 个服务器结果一致或者合并所有结果时用它的 Quorum 和 Aggregate。
 func first(servers ...*httptest.Server) (result, error) {
-	c := make(chan result)
+	c := make(chan result, len(servers)) // 带缓冲：输掉的goroutine返回结果时不会永远阻塞
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
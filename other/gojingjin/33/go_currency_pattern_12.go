@@ -14,6 +14,8 @@ import (
 	"time"
 )
 
+// 可以配置 http.Client、按退避重试幂等请求并遵守 Retry-After、限制响应体大小，
+// 并返回 ErrTimeout、*ErrStatus、ErrTooLarge 这些类型化错误的版本见 CInG/fetch 包。
 func fetchAPI(ctx context.Context, url string) (string, error) {
 	// 1. 创建绑定到上下文的HTTP请求
 	// http.NewRequestWithContext将传入的ctx与请求绑定
@@ -3,113 +3,251 @@ package main
 // 生产者消费者模型啊
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
-	"sync"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
 	"time"
+
+	"CInG/lifecycle"
+	"CInG/order"
 )
 
-// 订单结构体
-type Order struct {
-	ID     int
-	Amount float64
-	Items  []string
-}
+func main1() {
+	const (
+		minConsumers = 2 // 消费者协程数量随队列长度在2-10之间伸缩
+		maxConsumers = 10
+		totalOrders  = 50  // 总共生成50个订单
+		queueSize    = 100 // 使用带缓冲的channel作为订单队列 (容量100)
+	)
 
-// 生成随机订单数据
-func generateOrder(id int) Order {
-	items := []string{"T-Shirt", "Laptop", "Book", "Headphones", "Camera"}
-	rand.Shuffle(len(items), func(i, j int) {
-		items[i], items[j] = items[j], items[i]
-	})
+	fmt.Println("🚀 启动订单处理系统...")
+	fmt.Printf("🛒 生产者开始生成订单 | 👥 创建%d个消费者，最多扩容到%d个\n", minConsumers, maxConsumers)
+
+	var p *order.Processor
 
-	itemCount := rand.Intn(3) + 1 // 1-3个商品
-	return Order{
-		ID:     id,
-		Amount: float64(rand.Intn(500)+50) + rand.Float64(), // 50.00-549.99
-		Items:  items[:itemCount],
+	// 预写日志：上次进程挂掉时没处理完的订单会先被重新处理
+	journal, err := order.OpenJournal("orders.wal")
+	if err != nil {
+		fmt.Println("打开订单日志失败:", err)
+		return
 	}
-}
+	defer journal.Close()
 
-func main1() {
-	rand.Seed(time.Now().UnixNano())
+	// 新订单的ID接在未处理完的订单后面，避免重复
+	orderID := 0
+	for _, o := range journal.Pending() {
+		orderID = max(orderID, o.ID)
+	}
+	if n := len(journal.Pending()); n > 0 {
+		fmt.Printf("♻️ 重新处理上次未完成的%d个订单\n", n)
+	}
 
-	// 使用带缓冲的channel作为订单队列 (容量100)
-	orderQueue := make(chan Order, 100)
+	// 订单流、每个订单的处理时间和支付结果都由种子决定：设置 ORDER_SEED 可以复现某一次运行的订单、重试和死信，
+	// 只是输出的先后顺序和扩缩容的时机仍然取决于goroutine调度
+	seed := uint64(time.Now().UnixNano())
+	if v, err := strconv.ParseUint(os.Getenv("ORDER_SEED"), 10, 64); err == nil {
+		seed = v
+	}
+	fmt.Printf("🎲 订单随机种子: %d\n", seed)
+
+	gen := order.NewGenerator(order.GeneratorConfig{
+		Seed:        seed,
+		FirstID:     orderID + 1,
+		Count:       totalOrders,
+		Amount:      order.UniformAmount(50, 550),                  // 50.00-549.99
+		Arrival:     order.UniformArrival(0, 150*time.Millisecond), // 模拟随机订单到达间隔
+		ExpressRate: 0.1,                                           // 10%加急订单
+	})
 
-	// WaitGroup用于等待所有消费者完成
-	var wg sync.WaitGroup
+	// 生产者：生成订单，产完之后返回io.EOF，Processor会关闭订单队列通知消费者
+	source := order.SourceFunc(func(ctx context.Context) (order.Order, error) {
+		o, err := gen.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			fmt.Printf("\n🛑 生产者已创建所有%d个订单，关闭订单队列\n", totalOrders)
+		}
+		if err != nil {
+			return o, err
+		}
 
-	// 创建10个消费者协程
-	const numConsumers = 10
-	wg.Add(numConsumers)
+		fmt.Printf("📦 生产者: 创建订单 #%d (%.2f) - %v | 队列状态: %d/%d\n",
+			o.ID, o.Amount, o.Items, p.QueueLen(), p.QueueCap())
+		return o, nil
+	})
 
-	fmt.Println("🚀 启动订单处理系统...")
-	fmt.Printf("🛒 生产者开始生成订单 | 👥 创建%d个消费者\n", numConsumers)
+	// 消费者：模拟订单处理时间和支付
+	handler := func(ctx context.Context, o order.Order) error {
+		// 按订单ID和第几次尝试派生随机数，结果和哪个消费者、什么时候处理这个订单无关
+		r := rand.New(rand.NewPCG(seed, uint64(o.ID)<<8|uint64(o.Attempts)))
+		processTime := time.Duration(r.IntN(800)+200) * time.Millisecond
+		select {
+		case <-time.After(processTime):
+		case <-ctx.Done(): // 强制停止时放弃处理
+			return ctx.Err()
+		}
 
-	// 生产者：生成并发送订单
-	go func() {
-		const totalOrders = 50 // 总共生成50个订单
+		if r.Float32() < 0.92 { // 92%支付成功率
+			return nil
+		}
+		return &order.PaymentError{OrderID: o.ID, Reason: "支付网关超时", Temporary: true}
+	}
 
-		for orderID := 1; orderID <= totalOrders; orderID++ {
-			order := generateOrder(orderID)
+	// 失败订单最多尝试3次，仍然失败就进入死信通道，不再悄悄丢掉。
+	retry := order.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+		Jitter:      0.2,
+	}
+	deadLetter := make(chan order.Order, totalOrders+len(journal.Pending()))
+
+	p = order.NewProcessor(source, handler, minConsumers, queueSize,
+		order.WithRetry(retry), order.WithDeadLetter(deadLetter), order.WithJournal(journal),
+		// 重试和日志重放都可能让同一个订单ID出现两次，记住10分钟内处理成功的订单，避免重复扣款。
+		order.WithDedup(order.NewDeduper(10000, 10*time.Minute)),
+		// 大额(>=400)或加急订单优先处理，每连续处理3个之后给普通订单让一次，避免普通订单饿死。
+		order.WithPriority(order.PriorityPolicy{AmountThreshold: 400, LowEvery: 3}),
+		// 队列连续1秒超过5个订单就扩容，连续1秒少于1个就缩掉空闲的消费者。
+		order.WithAutoscale(order.AutoscalePolicy{
+			Min: minConsumers, Max: maxConsumers,
+			HighWater: 5, LowWater: 1,
+			Interval: 500 * time.Millisecond, Sustain: 2,
+		}))
+
+	// 启动顺序：处理器 -> 指标服务 -> 监控；退出时反过来。
+	// 第一次Ctrl+C(或SIGTERM)：停止接单，最多等10秒把队列里的订单处理完，超时就强制停止处理器；
+	// 再按一次Ctrl+C：强制停止处理器，打印没处理完的订单后退出进程，它们下次启动时从日志里重新处理。
+	app := lifecycle.NewApp(
+		lifecycle.WithShutdownTimeout(10*time.Second),
+		lifecycle.WithForceExit(func() {
+			fmt.Println("\n⛔ 强制停止处理器")
+			printSummary(p.HardStop())
+			os.Exit(1)
+		}))
+
+	// 订单都处理完之后取消 ctx，不用等信号就开始退出
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := false
+	resultsDone := make(chan struct{})
+	stopping := make(chan struct{})              // 处理器的停止钩子开始执行时关闭
+	summaryCh := make(chan order.StopSummary, 1) // Drain 超时时停止钩子可能比 Run 晚返回，通过通道交回汇总
+	app.Register("processor", func(context.Context) error {
+		// 处理器的生命周期由 Drain 控制，不挂在启动用的 ctx 上
+		if err := p.Start(context.Background()); err != nil {
+			return err
+		}
+		started = true
+		go printResults(p, resultsDone, cancel)
+		return nil
+	}, func(ctx context.Context) error {
+		fmt.Println("\n⏸️ 停止接单，处理队列中剩余的订单(最多10秒，再按一次Ctrl+C强制退出)...")
+		close(stopping)
+		summaryCh <- p.Drain(ctx)
+		return nil
+	})
 
-			// 模拟随机订单到达间隔
-			interval := time.Duration(rand.Intn(150)) * time.Millisecond
-			time.Sleep(interval)
+	// Prometheus格式的指标：curl http://127.0.0.1:9090/metrics
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p.MetricsHandler())
+	srv := &http.Server{Addr: "127.0.0.1:9090", Handler: mux}
+	app.Register("metrics", func(context.Context) error {
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Println("指标服务启动失败:", err)
+			}
+		}()
+		return nil
+	}, srv.Shutdown)
+
+	// 监控队列状态
+	stopMonitor := make(chan struct{})
+	app.Register("monitor", func(context.Context) error {
+		go monitor(p, stopMonitor)
+		return nil
+	}, func(context.Context) error {
+		close(stopMonitor)
+		return nil
+	})
 
-			fmt.Printf("📦 生产者: 创建订单 #%d (%.2f) - %v | 队列状态: %d/%d\n",
-				order.ID, order.Amount, order.Items, len(orderQueue), cap(orderQueue))
+	if err := app.Run(ctx); err != nil {
+		fmt.Println("退出时出错:", err)
+	}
+	if !started {
+		return
+	}
 
-			// 将订单发送到队列(阻塞操作直到有可用空间)
-			orderQueue <- order
-		}
+	// 停止钩子可能还在 Drain 里(超时后退化成 HardStop)，等它返回；
+	// 前面的组件用完了退出时间、停止钩子没来得及执行时，直接强制停止。
+	var summary order.StopSummary
+	select {
+	case <-stopping:
+		summary = <-summaryCh
+	default:
+		summary = p.HardStop()
+	}
 
-		fmt.Printf("\n🛑 生产者已创建所有%d个订单，关闭订单队列\n", totalOrders)
-		close(orderQueue) // 关闭通道以通知消费者
-	}()
-
-	time.Sleep(1500 * time.Millisecond)
-
-	// 启动消费者协程。先创建消费者协程，再创建生产者协程吗？哈吉并，你这家伙。
-	for i := 1; i <= numConsumers; i++ {
-		go func(consumerID int) {
-			defer wg.Done() // 一个consumer的range关闭了，就Done。所有consumer的range关闭了，就表示orderQueue真的没有了！
-
-			for order := range orderQueue { // orderQueue通道关闭后，for range​​不会立即终止​​：for range会​​继续读取通道中剩余的所有数据​​，直到通道被完全清空。
-				fmt.Printf("👷 消费者%d 开始处理订单 #%d (金额: $%.2f)\n",
-					consumerID, order.ID, order.Amount)
-
-				// 模拟订单处理时间
-				processTime := time.Duration(rand.Intn(800)+200) * time.Millisecond
-				time.Sleep(processTime)
-
-				// 模拟支付处理
-				if rand.Float32() < 0.92 { // 92%支付成功率
-					fmt.Printf("✅ 消费者%d 成功处理订单 #%d | 耗时: %v\n",
-						consumerID, order.ID, processTime.Round(time.Millisecond))
-				} else {
-					fmt.Printf("❌ 消费者%d 支付失败 #%d | 耗时: %v\n",
-						consumerID, order.ID, processTime.Round(time.Millisecond))
-				}
-			}
+	// 所有消费者退出后Results通道关闭
+	<-resultsDone
 
-			fmt.Printf("🛑 消费者%d 停止工作\n", consumerID)
-		}(i)
+	// 消费者都退出了，不会再有死信写入
+	close(deadLetter)
+	for o := range deadLetter {
+		fmt.Printf("☠️ 死信订单 #%d (%.2f) | 尝试%d次 | 最后错误: %v\n", o.ID, o.Amount, o.Attempts, o.LastErr)
 	}
 
-	// 主协程监控队列状态
+	fmt.Println("\n🔚 系统关闭")
+	printSummary(summary)
+}
+
+// printSummary 打印停止汇总，包括没处理完的订单。
+func printSummary(s order.StopSummary) {
+	fmt.Printf("已处理 %d 个订单 | 未完成 %d 个订单\n", s.Processed, len(s.Unfinished))
+	if s.TimedOut {
+		fmt.Println("⚠️ 没能在截止时间内处理完队列，已强制停止")
+	}
+	for _, o := range s.Unfinished {
+		fmt.Printf("⏳ 未完成订单 #%d (%.2f)，下次启动时会从日志中重新处理\n", o.ID, o.Amount)
+	}
+}
+
+// printResults 打印处理结果，Results通道关闭(所有消费者都退出)之后调用 allDone。
+func printResults(p *order.Processor, done chan<- struct{}, allDone func()) {
+	defer close(done)
+	defer allDone()
+
+	for r := range p.Results() {
+		if r.Duplicate {
+			fmt.Printf("🔁 消费者%d 跳过重复订单 #%d\n", r.Consumer, r.Order.ID)
+			continue
+		}
+		if r.Err != nil {
+			fmt.Printf("❌ 消费者%d 支付失败 #%d | 尝试%d次 | 耗时: %v\n",
+				r.Consumer, r.Order.ID, r.Order.Attempts, r.Duration.Round(time.Millisecond))
+			continue
+		}
+		fmt.Printf("✅ 消费者%d 成功处理订单 #%d | 尝试%d次 | 耗时: %v\n",
+			r.Consumer, r.Order.ID, r.Order.Attempts, r.Duration.Round(time.Millisecond))
+	}
+}
+
+func monitor(p *order.Processor, stop <-chan struct{}) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
-	go func() {
-		for range ticker.C { // 这种判定channel长度，但是不依靠判定结果读写的情况，并不会出现竞态。
-			fmt.Printf("📊 监控: 当前队列长度 %d/%d | 活跃消费者: %d\n",
-				len(orderQueue), cap(orderQueue), numConsumers)
+	for {
+		select {
+		case <-ticker.C:
+			m := p.Metrics()
+			fmt.Printf("📊 监控: 当前队列长度 %d/%d | 活跃消费者: %d | 已入队 %d 已处理 %d 失败 %d\n",
+				m.QueueLen, m.QueueCap, m.Consumers, m.Enqueued, m.Processed, m.Failed)
+		case <-stop:
+			return
 		}
-	}()
-
-	// 等待所有消费者完成工作
-	wg.Wait()
-	fmt.Println("\n🔚 所有订单处理完成，系统关闭")
+	}
 }
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"os/signal"
 	"syscall"
 	"time"
 
+	"CInG/lifecycle"
 	"github.com/gen2brain/beeep"
 	"github.com/getlantern/systray"
 	"golang.org/x/sys/windows/registry"
@@ -26,16 +26,46 @@ type Alarm struct {
 }
 
 func main() {
-	// 启动系统托盘
-	go startSystray()
-	<-systrayReady // 等待系统托盘初始化完成
+	// 托盘菜单里选择退出时取消 ctx，和按Ctrl+C一样走正常的退出流程
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-quitChan
+		fmt.Println("\n用户退出程序")
+		cancel()
+	}()
 
-	fmt.Println("闹钟程序已启动! 按Ctrl+C退出或在系统托盘选择退出")
+	app := lifecycle.NewApp(lifecycle.WithShutdownTimeout(5 * time.Second))
 
-	// 设置中断信号捕获
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	// 启动系统托盘
+	app.Register("systray", func(context.Context) error {
+		go startSystray()
+		<-systrayReady // 等待系统托盘初始化完成
+		return nil
+	}, func(context.Context) error {
+		systray.Quit()
+		return nil
+	})
+
+	// 每小时提醒一次
+	stopAlarm := make(chan struct{})
+	app.Register("alarm", func(context.Context) error {
+		go alarmLoop(stopAlarm)
+		fmt.Println("闹钟程序已启动! 按Ctrl+C退出或在系统托盘选择退出")
+		return nil
+	}, func(context.Context) error {
+		close(stopAlarm)
+		return nil
+	})
+
+	if err := app.Run(ctx); err != nil {
+		fmt.Println("退出时出错:", err)
+	}
+	fmt.Println("\n程序已退出")
+}
 
+// alarmLoop 启动时提醒一次，之后每1小时提醒一次，直到 stop 被关闭
+func alarmLoop(stop <-chan struct{}) {
 	// 首次提醒
 	go triggerAlarm()
 
@@ -43,20 +73,12 @@ func main() {
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
 
-	// 主循环
-loop:
 	for {
 		select {
 		case <-ticker.C:
 			go triggerAlarm()
-		case <-interrupt:
-			fmt.Println("\n程序已退出")
-			systray.Quit()
-			break loop
-		case <-quitChan:
-			fmt.Println("\n用户退出程序")
-			systray.Quit()
-			break loop
+		case <-stop:
+			return
 		}
 	}
 }